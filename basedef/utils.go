@@ -20,7 +20,9 @@ package basedef
 import (
 	"fmt"
 	"github.com/astaxie/beego/logs"
+	"github.com/shopspring/decimal"
 	"io/ioutil"
+	"math"
 	"os"
 )
 
@@ -28,6 +30,57 @@ var (
 	MARKET_COINMARKETCAP = "coinmarketcap"
 	MARKET_BINANCE       = "binance"
 	MARKET_HUOBI         = "huobi"
+	MARKET_OKX           = "okx"
+	MARKET_COINBASE      = "coinbase"
+	MARKET_KRAKEN        = "kraken"
+	MARKET_COINGECKO     = "coingecko"
+)
+
+var (
+	AGGREGATE_MEAN         = "mean"
+	AGGREGATE_MEDIAN       = "median"
+	AGGREGATE_TRIMMED_MEAN = "trimmed_mean"
+	AGGREGATE_MAD          = "mad"
+	AGGREGATE_VWAP         = "vwap"
+	AGGREGATE_WEIGHTED     = "weighted"
+	AGGREGATE_PRIMARY      = "primary"
+)
+
+// PriceInd of TokenBasic and PriceMarket
+var (
+	// the price is not updated in the last update
+	PRICE_IND_NOT_UPDATED = uint64(0)
+	// the price is updated in the last update
+	PRICE_IND_FRESH = uint64(1)
+	// the price is older than the max age and should not be trusted
+	PRICE_IND_STALE = uint64(2)
+)
+
+// Type of PriceNotify
+var (
+	NOTIFY_RULE_CROSS  = "cross"
+	NOTIFY_RULE_BAND   = "band"
+	NOTIFY_RULE_WINDOW = "window"
+	NOTIFY_RULE_SPREAD = "spread"
+)
+
+// Severity of PriceNotify
+var (
+	NOTIFY_SEVERITY_INFO     = "info"
+	NOTIFY_SEVERITY_WARNING  = "warning"
+	NOTIFY_SEVERITY_CRITICAL = "critical"
+)
+
+// Type of notify channel
+var (
+	NOTIFY_CHANNEL_DING = "ding"
+)
+
+// Direction of PriceNotify
+var (
+	NOTIFY_DIRECTION_BOTH = int64(0)
+	NOTIFY_DIRECTION_UP   = int64(1)
+	NOTIFY_DIRECTION_DOWN = int64(-1)
 )
 
 var (
@@ -37,8 +90,44 @@ var (
 
 var (
 	PRICE_PRECISION = int64(100000000)
+	// decimal places of PRICE_PRECISION
+	PRICE_DECIMALS = int32(8)
+)
+
+// Property of TokenBasic
+var (
+	// the property is not set, the token is the same as TOKEN_PROPERTY_NORMAL
+	TOKEN_PROPERTY_UNSET = int64(0)
+	// the price of token is updated and notified
+	TOKEN_PROPERTY_NORMAL = int64(1)
+	// the token is kept in db, but its price is neither updated nor notified
+	TOKEN_PROPERTY_DISABLED = int64(2)
 )
 
+// TokenDecimals returns the decimal places of token price by the Precision of token,
+// PRICE_DECIMALS if it is not set.
+func TokenDecimals(precision uint64) int32 {
+	if precision == 0 {
+		return PRICE_DECIMALS
+	}
+	return int32(precision)
+}
+
+var (
+	maxInt64 = decimal.NewFromInt(math.MaxInt64)
+	minInt64 = decimal.NewFromInt(math.MinInt64)
+)
+
+// PriceToInt64 scales price by decimals places and rounds it half to even, an error is returned if the
+// scaled price overflows int64.
+func PriceToInt64(price decimal.Decimal, decimals int32) (int64, error) {
+	scaled := price.Shift(decimals).RoundBank(0)
+	if scaled.GreaterThan(maxInt64) || scaled.LessThan(minInt64) {
+		return 0, fmt.Errorf("price %s with %d decimals overflows int64", price.String(), decimals)
+	}
+	return scaled.IntPart(), nil
+}
+
 func ReadFile(fileName string) ([]byte, error) {
 	file, err := os.OpenFile(fileName, os.O_RDONLY, 0666)
 	if err != nil {
@@ -29,6 +29,17 @@ type CoinPriceDao interface {
 	GetTokens() ([]*models.TokenBasic, error)
 	AddTokens(tokens []*models.TokenBasic) error
 	SavePrices(tokens []*models.TokenBasic) error
+	AddPriceHistories(histories []*models.PriceHistory) error
+	// GetPriceHistories returns the histories of bucket whose time is in [start, end)
+	GetPriceHistories(bucket int64, start int64, end int64) ([]*models.PriceHistory, error)
+	// GetLastPriceHistoryTime returns the time of the last history of bucket, 0 if there is none
+	GetLastPriceHistoryTime(bucket int64) (int64, error)
+	// DeletePriceHistories deletes the histories of bucket whose time is before the time
+	DeletePriceHistories(bucket int64, before int64) error
+	// AddPriceCandles adds the candles, the candles of the same token, period and time are replaced
+	AddPriceCandles(candles []*models.PriceCandle) error
+	// GetPartialPriceCandles returns the partial candles
+	GetPartialPriceCandles() ([]*models.PriceCandle, error)
 	Name() string
 }
 
@@ -21,12 +21,18 @@ import (
 	"fmt"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 	"price_notify/basedef"
 	"price_notify/conf"
 	"price_notify/models"
 )
 
+var (
+	// rows of price history or candles in a batch insert
+	HISTORY_BATCH_SIZE = 500
+)
+
 type PriceDao struct {
 	dbCfg *conf.DBConfig
 	db    *gorm.DB
@@ -84,6 +90,63 @@ func (dao *PriceDao) AddTokens(tokens []*models.TokenBasic) error {
 	return nil
 }
 
+func (dao *PriceDao) AddPriceHistories(histories []*models.PriceHistory) error {
+	if histories != nil && len(histories) > 0 {
+		res := dao.db.CreateInBatches(histories, HISTORY_BATCH_SIZE)
+		if res.Error != nil {
+			return res.Error
+		}
+	}
+	return nil
+}
+
+func (dao *PriceDao) GetPriceHistories(bucket int64, start int64, end int64) ([]*models.PriceHistory, error) {
+	histories := make([]*models.PriceHistory, 0)
+	res := dao.db.Where("bucket = ? and time >= ? and time < ?", bucket, start, end).Find(&histories)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return histories, nil
+}
+
+func (dao *PriceDao) GetLastPriceHistoryTime(bucket int64) (int64, error) {
+	last := int64(0)
+	res := dao.db.Model(&models.PriceHistory{}).Select("COALESCE(MAX(time), 0)").Where("bucket = ?", bucket).Scan(&last)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	return last, nil
+}
+
+func (dao *PriceDao) DeletePriceHistories(bucket int64, before int64) error {
+	res := dao.db.Where("bucket = ? and time < ?", bucket, before).Delete(&models.PriceHistory{})
+	if res.Error != nil {
+		return res.Error
+	}
+	return nil
+}
+
+func (dao *PriceDao) AddPriceCandles(candles []*models.PriceCandle) error {
+	if candles != nil && len(candles) > 0 {
+		res := dao.db.Clauses(clause.OnConflict{
+			DoUpdates: clause.AssignmentColumns([]string{"open", "high", "low", "close", "count", "gap", "partial"}),
+		}).CreateInBatches(candles, HISTORY_BATCH_SIZE)
+		if res.Error != nil {
+			return res.Error
+		}
+	}
+	return nil
+}
+
+func (dao *PriceDao) GetPartialPriceCandles() ([]*models.PriceCandle, error) {
+	candles := make([]*models.PriceCandle, 0)
+	res := dao.db.Where("partial = ?", true).Find(&candles)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return candles, nil
+}
+
 func (dao *PriceDao) Name() string {
 	return basedef.SERVER_PRICE
 }
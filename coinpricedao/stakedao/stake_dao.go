@@ -49,6 +49,30 @@ func (dao *StakeDao) AddTokens(tokens []*models.TokenBasic) error {
 	return nil
 }
 
+func (dao *StakeDao) AddPriceHistories(histories []*models.PriceHistory) error {
+	return nil
+}
+
+func (dao *StakeDao) GetPriceHistories(bucket int64, start int64, end int64) ([]*models.PriceHistory, error) {
+	return nil, nil
+}
+
+func (dao *StakeDao) GetLastPriceHistoryTime(bucket int64) (int64, error) {
+	return 0, nil
+}
+
+func (dao *StakeDao) DeletePriceHistories(bucket int64, before int64) error {
+	return nil
+}
+
+func (dao *StakeDao) AddPriceCandles(candles []*models.PriceCandle) error {
+	return nil
+}
+
+func (dao *StakeDao) GetPartialPriceCandles() ([]*models.PriceCandle, error) {
+	return nil, nil
+}
+
 func (dao *StakeDao) Name() string {
 	return basedef.SERVER_STAKE
 }
@@ -0,0 +1,279 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package coinpricelisten
+
+import (
+	"fmt"
+	"github.com/astaxie/beego/logs"
+	"github.com/shopspring/decimal"
+	"price_notify/basedef"
+	"price_notify/conf"
+	"price_notify/models"
+	"sort"
+)
+
+var (
+	DEFAULT_TRIM_PERCENT  = int64(20)
+	DEFAULT_MAD_THRESHOLD = float64(3)
+	// MAD is scaled to be comparable with the standard deviation of normal distribution
+	MAD_SCALE = decimal.RequireFromString("1.4826")
+	// the MAD limit is at least MAD_MIN_DEVIATION of the median, so a price differing by a tick is not rejected
+	// when most of the prices are the same and MAD is 0
+	MAD_MIN_DEVIATION   = decimal.RequireFromString("0.001")
+	DEFAULT_MIN_SOURCES = int64(1)
+	DEFAULT_JUMP_TICKS  = int64(3)
+)
+
+func newAggregateConfig(cfg *conf.CoinPriceAggregateConfig) *conf.CoinPriceAggregateConfig {
+	aggregateCfg := &conf.CoinPriceAggregateConfig{}
+	if cfg != nil {
+		*aggregateCfg = *cfg
+	}
+	if aggregateCfg.Strategy == "" {
+		aggregateCfg.Strategy = basedef.AGGREGATE_MEAN
+	}
+	if aggregateCfg.TrimPercent <= 0 || aggregateCfg.TrimPercent >= 50 {
+		aggregateCfg.TrimPercent = DEFAULT_TRIM_PERCENT
+	}
+	if aggregateCfg.MadThreshold <= 0 {
+		aggregateCfg.MadThreshold = DEFAULT_MAD_THRESHOLD
+	}
+	if aggregateCfg.MinSources <= 0 {
+		aggregateCfg.MinSources = DEFAULT_MIN_SOURCES
+	}
+	if aggregateCfg.MaxAge < 0 {
+		aggregateCfg.MaxAge = 0
+	}
+	if aggregateCfg.JumpPercent < 0 {
+		aggregateCfg.JumpPercent = 0
+	}
+	if aggregateCfg.JumpConfirmTicks <= 0 {
+		aggregateCfg.JumpConfirmTicks = DEFAULT_JUMP_TICKS
+	}
+	return aggregateCfg
+}
+
+// minSources returns the quorum of token, the one of token is used if it is set, otherwise the global one.
+func (cpl *CoinPriceListen) minSources(tokenBasic *models.TokenBasic) int64 {
+	if tokenBasic.MinSources > 0 {
+		return tokenBasic.MinSources
+	}
+	return cpl.aggregateCfg.MinSources
+}
+
+// maxAge returns the max age of token, the one of token is used if it is set, otherwise the global one.
+func (cpl *CoinPriceListen) maxAge(tokenBasic *models.TokenBasic) int64 {
+	if tokenBasic.MaxAge > 0 {
+		return tokenBasic.MaxAge
+	}
+	return cpl.aggregateCfg.MaxAge
+}
+
+// sourcePrice is the price of a market in an update, it is not rounded to the precision of token
+type sourcePrice struct {
+	tokenPrice *models.PriceMarket
+	price      decimal.Decimal
+}
+
+// aggregatePrice computes the price of token from the markets which have a price in this update. The strategy
+// of token is used if it is set, otherwise the global one. The markets which are not used are marked with
+// the reason in ExcludeReason. The market prices older than the max age are stale and not used, and no price
+// is published if there are fewer market prices than the quorum. The market prices are aggregated as they are
+// quoted and the result is rounded once to the precision of token.
+func (cpl *CoinPriceListen) aggregatePrice(tokenBasic *models.TokenBasic, prices map[*models.PriceMarket]decimal.Decimal, now int64) (int64, bool) {
+	maxAge := cpl.maxAge(tokenBasic)
+	sourcePrices := make([]*sourcePrice, 0)
+	for _, tokenPrice := range tokenBasic.PriceMarkets {
+		if tokenPrice.PriceInd != basedef.PRICE_IND_FRESH {
+			continue
+		}
+		if maxAge > 0 && now-tokenPrice.Time > maxAge {
+			tokenPrice.PriceInd = basedef.PRICE_IND_STALE
+			tokenPrice.ExcludeReason = fmt.Sprintf("price time %d is older than max age %d", tokenPrice.Time, maxAge)
+			logs.Warn("price of token %s in market %s is excluded: %s", tokenBasic.Name, tokenPrice.MarketName, tokenPrice.ExcludeReason)
+			continue
+		}
+		sourcePrices = append(sourcePrices, &sourcePrice{tokenPrice: tokenPrice, price: prices[tokenPrice]})
+	}
+	if len(sourcePrices) == 0 {
+		return 0, false
+	}
+	minSources := cpl.minSources(tokenBasic)
+	if int64(len(sourcePrices)) < minSources {
+		logs.Error("price of token %s has %d sources, fewer than quorum %d", tokenBasic.Name, len(sourcePrices), minSources)
+		return 0, false
+	}
+	strategy := cpl.aggregateCfg.Strategy
+	if tokenBasic.Aggregation != "" {
+		strategy = tokenBasic.Aggregation
+	}
+	sort.SliceStable(sourcePrices, func(i, j int) bool {
+		return sourcePrices[i].price.LessThan(sourcePrices[j].price)
+	})
+	price := decimal.Zero
+	switch strategy {
+	case basedef.AGGREGATE_MEAN:
+		price = meanPrice(sourcePrices)
+	case basedef.AGGREGATE_MEDIAN:
+		price = medianPrice(sourcePrices)
+	case basedef.AGGREGATE_TRIMMED_MEAN:
+		price = trimmedMeanPrice(sourcePrices, cpl.aggregateCfg.TrimPercent)
+	case basedef.AGGREGATE_MAD:
+		price = madPrice(sourcePrices, cpl.aggregateCfg.MadThreshold)
+	case basedef.AGGREGATE_VWAP:
+		price = vwapPrice(sourcePrices)
+	case basedef.AGGREGATE_WEIGHTED:
+		price = weightedPrice(sourcePrices)
+	case basedef.AGGREGATE_PRIMARY:
+		price = primaryPrice(sourcePrices)
+	default:
+		logs.Error("unknown aggregation %s of token %s, use mean", strategy, tokenBasic.Name)
+		price = meanPrice(sourcePrices)
+	}
+	for _, source := range sourcePrices {
+		tokenPrice := source.tokenPrice
+		if tokenPrice.ExcludeReason != "" {
+			logs.Warn("price of token %s in market %s is excluded: %s", tokenBasic.Name, tokenPrice.MarketName, tokenPrice.ExcludeReason)
+		}
+	}
+	result, err := basedef.PriceToInt64(price, basedef.TokenDecimals(tokenBasic.Precision))
+	if err != nil {
+		logs.Error("price of token %s err: %v", tokenBasic.Name, err)
+		return 0, false
+	}
+	return result, true
+}
+
+func meanPrice(sourcePrices []*sourcePrice) decimal.Decimal {
+	price := decimal.Zero
+	for _, source := range sourcePrices {
+		price = price.Add(source.price)
+	}
+	return price.Div(decimal.NewFromInt(int64(len(sourcePrices))))
+}
+
+// medianPrice returns the median of prices which are sorted
+func medianPrice(sourcePrices []*sourcePrice) decimal.Decimal {
+	middle := len(sourcePrices) / 2
+	if len(sourcePrices)%2 == 1 {
+		return sourcePrices[middle].price
+	}
+	return meanPrice(sourcePrices[middle-1 : middle+1])
+}
+
+// trimmedMeanPrice drops trimPercent of the sorted prices from each side and returns the mean of the others
+func trimmedMeanPrice(sourcePrices []*sourcePrice, trimPercent int64) decimal.Decimal {
+	trim := len(sourcePrices) * int(trimPercent) / 100
+	for i := 0; i < trim; i++ {
+		sourcePrices[i].tokenPrice.ExcludeReason = fmt.Sprintf("trimmed as one of the lowest %d%% prices", trimPercent)
+		sourcePrices[len(sourcePrices)-1-i].tokenPrice.ExcludeReason = fmt.Sprintf("trimmed as one of the highest %d%% prices", trimPercent)
+	}
+	return meanPrice(sourcePrices[trim : len(sourcePrices)-trim])
+}
+
+// madPrice rejects the prices whose deviation from the median is larger than threshold scaled median absolute
+// deviation, and returns the mean of the others. An outlier can only be told with at least 3 prices. The limit
+// is at least MAD_MIN_DEVIATION of the median.
+func madPrice(sourcePrices []*sourcePrice, threshold float64) decimal.Decimal {
+	if len(sourcePrices) < 3 {
+		return meanPrice(sourcePrices)
+	}
+	median := medianPrice(sourcePrices)
+	deviations := make([]*sourcePrice, 0, len(sourcePrices))
+	for _, source := range sourcePrices {
+		deviations = append(deviations, &sourcePrice{price: source.price.Sub(median).Abs()})
+	}
+	sort.Slice(deviations, func(i, j int) bool {
+		return deviations[i].price.LessThan(deviations[j].price)
+	})
+	mad := medianPrice(deviations)
+	limit := decimal.NewFromFloat(threshold).Mul(MAD_SCALE).Mul(mad)
+	limit = decimal.Max(limit, MAD_MIN_DEVIATION.Mul(median))
+	accepted := make([]*sourcePrice, 0, len(sourcePrices))
+	for _, source := range sourcePrices {
+		deviation := source.price.Sub(median).Abs()
+		if deviation.GreaterThan(limit) {
+			source.tokenPrice.ExcludeReason = fmt.Sprintf("deviation %s from median %s is larger than %g MAD limit %s",
+				deviation.String(), median.String(), threshold, limit.String())
+			continue
+		}
+		accepted = append(accepted, source)
+	}
+	return meanPrice(accepted)
+}
+
+// vwapPrice returns the average of prices weighted by the 24h volume, the markets without volume are excluded.
+// It is the mean of prices if there is no volume in any market.
+func vwapPrice(sourcePrices []*sourcePrice) decimal.Decimal {
+	totalVolume := decimal.Zero
+	totalPrice := decimal.Zero
+	for _, source := range sourcePrices {
+		if source.tokenPrice.Volume > 0 {
+			volume := decimal.NewFromInt(source.tokenPrice.Volume)
+			totalVolume = totalVolume.Add(volume)
+			totalPrice = totalPrice.Add(source.price.Mul(volume))
+		}
+	}
+	if totalVolume.IsZero() {
+		return meanPrice(sourcePrices)
+	}
+	for _, source := range sourcePrices {
+		if source.tokenPrice.Volume <= 0 {
+			source.tokenPrice.ExcludeReason = "there is no 24h volume for vwap"
+		}
+	}
+	return totalPrice.Div(totalVolume)
+}
+
+// weightedPrice returns the average of prices weighted by the Weight of markets, a market without
+// a positive weight has weight 1.
+func weightedPrice(sourcePrices []*sourcePrice) decimal.Decimal {
+	totalWeight := decimal.Zero
+	totalPrice := decimal.Zero
+	for _, source := range sourcePrices {
+		weight := source.tokenPrice.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		totalWeight = totalWeight.Add(decimal.NewFromInt(weight))
+		totalPrice = totalPrice.Add(source.price.Mul(decimal.NewFromInt(weight)))
+	}
+	return totalPrice.Div(totalWeight)
+}
+
+// primaryPrice returns the price of the market with the highest Priority, the markets with lower priority
+// are only used when the others have no price in this update. The prices of markets with the same
+// priority are averaged.
+func primaryPrice(sourcePrices []*sourcePrice) decimal.Decimal {
+	priority := sourcePrices[0].tokenPrice.Priority
+	for _, source := range sourcePrices {
+		if source.tokenPrice.Priority > priority {
+			priority = source.tokenPrice.Priority
+		}
+	}
+	primaries := make([]*sourcePrice, 0)
+	for _, source := range sourcePrices {
+		tokenPrice := source.tokenPrice
+		if tokenPrice.Priority < priority {
+			tokenPrice.ExcludeReason = fmt.Sprintf("priority %d is lower than the primary priority %d", tokenPrice.Priority, priority)
+			continue
+		}
+		primaries = append(primaries, source)
+	}
+	return meanPrice(primaries)
+}
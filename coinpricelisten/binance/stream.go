@@ -0,0 +1,249 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/astaxie/beego/logs"
+	"github.com/gorilla/websocket"
+	"github.com/shopspring/decimal"
+	"price_notify/basedef"
+	"price_notify/conf"
+	"price_notify/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	STREAM_MINITICKER = "miniTicker"
+	STREAM_BOOKTICKER = "bookTicker"
+)
+
+var (
+	// the price which is not updated in DEFAULT_STALE_SLOT seconds is stale
+	DEFAULT_STALE_SLOT = int64(60)
+	// wait RECONNECT_INTERVAL before connecting to the next node
+	RECONNECT_INTERVAL = time.Second
+)
+
+type streamPrice struct {
+	price  decimal.Decimal
+	volume decimal.Decimal
+	time   time.Time
+}
+
+// BinanceStreamSdk subscribes the miniTicker or bookTicker stream of the configured coins when it connects
+// and of the other coins when they are queried, and answers GetCoinPrice from the last prices received.
+// The connection is reconnected and the coins are subscribed again when it is broken or no message is
+// received in staleSlot.
+type BinanceStreamSdk struct {
+	nodes     []*conf.Restful
+	stream    string
+	staleSlot int64
+	symbols   map[string]bool
+	prices    map[string]*streamPrice
+	conn      *websocket.Conn
+	requestId int64
+	lock      sync.Mutex
+	writeLock sync.Mutex
+	exit      chan bool
+	closeOnce sync.Once
+}
+
+func NewBinanceStreamSdk(cfg *conf.CoinPriceListenConfig) *BinanceStreamSdk {
+	sdk := &BinanceStreamSdk{
+		nodes:     cfg.Nodes,
+		stream:    cfg.Stream,
+		staleSlot: cfg.StaleSlot,
+		symbols:   make(map[string]bool),
+		prices:    make(map[string]*streamPrice),
+		exit:      make(chan bool),
+	}
+	if sdk.stream != STREAM_BOOKTICKER {
+		sdk.stream = STREAM_MINITICKER
+	}
+	if sdk.staleSlot <= 0 {
+		sdk.staleSlot = DEFAULT_STALE_SLOT
+	}
+	for _, coin := range cfg.Coins {
+		sdk.symbols[strings.ToUpper(coin)] = true
+	}
+	go sdk.run()
+	return sdk
+}
+
+// Close stops the stream, it can be called more than once
+func (sdk *BinanceStreamSdk) Close() error {
+	sdk.closeOnce.Do(func() {
+		close(sdk.exit)
+		sdk.lock.Lock()
+		conn := sdk.conn
+		sdk.lock.Unlock()
+		if conn != nil {
+			conn.Close()
+		}
+	})
+	return nil
+}
+
+func (sdk *BinanceStreamSdk) run() {
+	for i := 0; ; i++ {
+		if len(sdk.nodes) == 0 {
+			logs.Error("there is no node of Binance stream")
+			return
+		}
+		err := sdk.serve(sdk.nodes[i%len(sdk.nodes)])
+		select {
+		case <-sdk.exit:
+			return
+		default:
+		}
+		logs.Error("Binance stream err: %v, reconnect after %s", err, RECONNECT_INTERVAL)
+		select {
+		case <-sdk.exit:
+			return
+		case <-time.After(RECONNECT_INTERVAL):
+		}
+	}
+}
+
+func (sdk *BinanceStreamSdk) serve(node *conf.Restful) error {
+	conn, _, err := websocket.DefaultDialer.Dial(node.Url+"ws", nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	staleTime := time.Second * time.Duration(sdk.staleSlot)
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(staleTime))
+		sdk.writeLock.Lock()
+		defer sdk.writeLock.Unlock()
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	sdk.lock.Lock()
+	sdk.conn = conn
+	symbols := make([]string, 0, len(sdk.symbols))
+	for symbol := range sdk.symbols {
+		symbols = append(symbols, symbol)
+	}
+	sdk.lock.Unlock()
+	defer func() {
+		sdk.lock.Lock()
+		sdk.conn = nil
+		sdk.lock.Unlock()
+	}()
+	logs.Info("Binance stream connected to %s", node.Url)
+	if len(symbols) > 0 {
+		err = sdk.subscribe(conn, symbols)
+		if err != nil {
+			return err
+		}
+	}
+	for {
+		conn.SetReadDeadline(time.Now().Add(staleTime))
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		sdk.handleMessage(message)
+	}
+}
+
+func (sdk *BinanceStreamSdk) subscribe(conn *websocket.Conn, symbols []string) error {
+	sort.Strings(symbols)
+	params := make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		params = append(params, strings.ToLower(symbol)+"@"+sdk.stream)
+	}
+	sdk.lock.Lock()
+	sdk.requestId++
+	request := &StreamRequest{
+		Method: "SUBSCRIBE",
+		Params: params,
+		Id:     sdk.requestId,
+	}
+	sdk.lock.Unlock()
+	sdk.writeLock.Lock()
+	defer sdk.writeLock.Unlock()
+	return conn.WriteJSON(request)
+}
+
+func (sdk *BinanceStreamSdk) handleMessage(message []byte) {
+	var symbol string
+	var price, volume decimal.Decimal
+	if sdk.stream == STREAM_BOOKTICKER {
+		ticker := new(BookTicker)
+		if err := json.Unmarshal(message, ticker); err != nil || ticker.Symbol == "" {
+			return
+		}
+		symbol, price = ticker.Symbol, ticker.BidPrice.Add(ticker.AskPrice).Div(decimal.NewFromInt(2))
+	} else {
+		ticker := new(MiniTicker)
+		if err := json.Unmarshal(message, ticker); err != nil || ticker.Symbol == "" {
+			return
+		}
+		symbol, price, volume = ticker.Symbol, ticker.Close, ticker.QuoteVolume
+	}
+	sdk.lock.Lock()
+	sdk.prices[symbol] = &streamPrice{price: price, volume: volume, time: time.Now()}
+	sdk.lock.Unlock()
+}
+
+func (sdk *BinanceStreamSdk) GetMarketName() string {
+	return basedef.MARKET_BINANCE
+}
+
+// GetCoinPrice returns the last prices of coins, the coins which are not subscribed yet are subscribed
+// and the coins without a fresh price are reported by the error.
+func (sdk *BinanceStreamSdk) GetCoinPrice(ctx context.Context, coins []string) (map[string]*models.CoinPrice, error) {
+	now := time.Now()
+	staleTime := time.Second * time.Duration(sdk.staleSlot)
+	coinPrice := make(map[string]*models.CoinPrice, 0)
+	newSymbols := make([]string, 0)
+	missed := make([]string, 0)
+	sdk.lock.Lock()
+	for _, coin := range coins {
+		symbol := strings.ToUpper(coin)
+		if !sdk.symbols[symbol] {
+			sdk.symbols[symbol] = true
+			newSymbols = append(newSymbols, symbol)
+		}
+		price, ok := sdk.prices[symbol]
+		if !ok || now.Sub(price.time) > staleTime {
+			missed = append(missed, coin)
+			continue
+		}
+		coinPrice[coin] = &models.CoinPrice{Price: price.price, Volume: price.volume, Time: price.time.Unix()}
+	}
+	conn := sdk.conn
+	sdk.lock.Unlock()
+	if len(newSymbols) > 0 && conn != nil {
+		err := sdk.subscribe(conn, newSymbols)
+		if err != nil {
+			logs.Error("Binance stream subscribe err: %v", err)
+		}
+	}
+	if len(missed) > 0 {
+		return coinPrice, fmt.Errorf("There is no fresh price of %s in Binance stream!", strings.Join(missed, ","))
+	}
+	return coinPrice, nil
+}
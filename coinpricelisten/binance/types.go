@@ -17,7 +17,48 @@
 
 package binance
 
+import "github.com/shopspring/decimal"
+
 type Ticker struct {
-	Symbol string  `json:"symbol"`
-	Price  float64 `json:"price,string"`
+	Symbol string          `json:"symbol"`
+	Price  decimal.Decimal `json:"price"`
+}
+
+// Ticker24hr is the 24 hours statistics of a symbol
+type Ticker24hr struct {
+	Symbol      string          `json:"symbol"`
+	LastPrice   decimal.Decimal `json:"lastPrice"`
+	Volume      decimal.Decimal `json:"volume"`
+	QuoteVolume decimal.Decimal `json:"quoteVolume"`
+	CloseTime   int64           `json:"closeTime"`
+}
+
+// MiniTicker is the event of <symbol>@miniTicker stream
+type MiniTicker struct {
+	Event       string          `json:"e"`
+	EventTime   int64           `json:"E"`
+	Symbol      string          `json:"s"`
+	Close       decimal.Decimal `json:"c"`
+	Open        decimal.Decimal `json:"o"`
+	High        decimal.Decimal `json:"h"`
+	Low         decimal.Decimal `json:"l"`
+	Volume      decimal.Decimal `json:"v"`
+	QuoteVolume decimal.Decimal `json:"q"`
+}
+
+// BookTicker is the event of <symbol>@bookTicker stream
+type BookTicker struct {
+	UpdateId int64           `json:"u"`
+	Symbol   string          `json:"s"`
+	BidPrice decimal.Decimal `json:"b"`
+	BidQty   decimal.Decimal `json:"B"`
+	AskPrice decimal.Decimal `json:"a"`
+	AskQty   decimal.Decimal `json:"A"`
+}
+
+// StreamRequest is sent to subscribe streams
+type StreamRequest struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	Id     int64    `json:"id"`
 }
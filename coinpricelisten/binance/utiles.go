@@ -18,18 +18,33 @@
 package binance
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/astaxie/beego/logs"
+	"github.com/shopspring/decimal"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"price_notify/basedef"
 	"price_notify/conf"
+	"price_notify/models"
+	"sync"
+	"time"
+)
+
+var (
+	// the 24h volumes are refreshed every VOLUME_REFRESH_SLOT seconds, as the 24hr ticker is heavy in weight
+	VOLUME_REFRESH_SLOT = int64(300)
 )
 
 type BinanceSdk struct {
 	client *http.Client
 	nodes  []*conf.Restful
+	// the 24h quote volumes of symbols and the time they are refreshed
+	volumes    map[string]decimal.Decimal
+	volumeTime int64
+	volumeLock sync.Mutex
 }
 
 func DefaultBinanceSdk() *BinanceSdk {
@@ -41,6 +56,7 @@ func DefaultBinanceSdk() *BinanceSdk {
 				Url: "https://api1.binance.com/",
 			},
 		},
+		volumes: make(map[string]decimal.Decimal),
 	}
 	return sdk
 }
@@ -48,15 +64,16 @@ func DefaultBinanceSdk() *BinanceSdk {
 func NewBinanceSdk(cfg *conf.CoinPriceListenConfig) *BinanceSdk {
 	client := &http.Client{}
 	sdk := &BinanceSdk{
-		client: client,
-		nodes:  cfg.Nodes,
+		client:  client,
+		nodes:   cfg.Nodes,
+		volumes: make(map[string]decimal.Decimal),
 	}
 	return sdk
 }
 
-func (sdk *BinanceSdk) QuotesLatest() ([]*Ticker, error) {
+func (sdk *BinanceSdk) QuotesLatest(ctx context.Context) ([]*Ticker, error) {
 	for i := 0; i < len(sdk.nodes); i++ {
-		quotes, err := sdk.quotesLatest(i)
+		quotes, err := sdk.quotesLatest(ctx, i)
 		if err != nil {
 			logs.Error("CoinMarketCap QuotesLatest err: %s", err.Error())
 			continue
@@ -67,14 +84,13 @@ func (sdk *BinanceSdk) QuotesLatest() ([]*Ticker, error) {
 	return nil, fmt.Errorf("Cannot get Binance QuotesLatest!")
 }
 
-func (sdk *BinanceSdk) quotesLatest(node int) ([]*Ticker, error) {
-	req, err := http.NewRequest("GET", sdk.nodes[node].Url+"api/v3/ticker/price", nil)
+func (sdk *BinanceSdk) quotesLatest(ctx context.Context, node int) ([]*Ticker, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", sdk.nodes[node].Url+"api/v3/ticker/price", nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Accepts", "application/json")
-	req.Header.Add("X-CMC_PRO_API_KEY", "8efe5156-8b37-4c77-8e1d-a140c97bf466")
 
 	resp, err := sdk.client.Do(req)
 	if err != nil {
@@ -93,27 +109,110 @@ func (sdk *BinanceSdk) quotesLatest(node int) ([]*Ticker, error) {
 	return tickers, nil
 }
 
+func (sdk *BinanceSdk) Tickers24hr(ctx context.Context, symbols []string) ([]*Ticker24hr, error) {
+	for i := 0; i < len(sdk.nodes); i++ {
+		tickers, err := sdk.tickers24hr(ctx, symbols, i)
+		if err != nil {
+			logs.Error("Binance Tickers24hr err: %s", err.Error())
+			continue
+		} else {
+			return tickers, nil
+		}
+	}
+	return nil, fmt.Errorf("Cannot get Binance Tickers24hr!")
+}
+
+func (sdk *BinanceSdk) tickers24hr(ctx context.Context, symbols []string, node int) ([]*Ticker24hr, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", sdk.nodes[node].Url+"api/v3/ticker/24hr", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	symbolsJson, _ := json.Marshal(symbols)
+	q := url.Values{}
+	q.Add("symbols", string(symbolsJson))
+
+	req.Header.Set("Accepts", "application/json")
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := sdk.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("response status code: %d", resp.StatusCode)
+	}
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	tickers := make([]*Ticker24hr, 0)
+	err = json.Unmarshal(respBody, &tickers)
+	if err != nil {
+		return nil, err
+	}
+	return tickers, nil
+}
+
 func (sdk *BinanceSdk) GetMarketName() string {
 	return basedef.MARKET_BINANCE
 }
 
-func (this *BinanceSdk) GetCoinPrice(coins []string) (map[string]float64, error) {
-	quotes, err := this.QuotesLatest()
+// GetCoinPrice returns the prices of coins from all tickers, and the 24h quote volumes of the coins
+// which are listed. The volumes are refreshed every VOLUME_REFRESH_SLOT seconds or when a coin has no volume,
+// and the prices are still returned without volume if the volumes are not available.
+func (this *BinanceSdk) GetCoinPrice(ctx context.Context, coins []string) (map[string]*models.CoinPrice, error) {
+	quotes, err := this.QuotesLatest(ctx)
 	if err != nil {
 		return nil, err
 	}
-	coinSymbol2Price := make(map[string]float64, 0)
+	now := time.Now().Unix()
+	coinSymbol2Price := make(map[string]decimal.Decimal, 0)
 	for _, v := range quotes {
 		coinSymbol2Price[v.Symbol] = v.Price
 	}
-	coinPrice := make(map[string]float64, 0)
+	coinPrice := make(map[string]*models.CoinPrice, 0)
+	symbols := make([]string, 0)
 	for _, coin := range coins {
 		price, ok := coinSymbol2Price[coin]
 		if !ok {
 			logs.Warn("There is no coin price %s in Binance!", coin)
 			continue
 		}
-		coinPrice[coin] = price
+		coinPrice[coin] = &models.CoinPrice{Price: price, Time: now}
+		symbols = append(symbols, coin)
+	}
+	volumes := this.getVolumes(ctx, symbols, now)
+	for coin, price := range coinPrice {
+		price.Volume = volumes[coin]
 	}
 	return coinPrice, nil
 }
+
+func (this *BinanceSdk) getVolumes(ctx context.Context, symbols []string, now int64) map[string]decimal.Decimal {
+	this.volumeLock.Lock()
+	defer this.volumeLock.Unlock()
+	refresh := now-this.volumeTime >= VOLUME_REFRESH_SLOT
+	for _, symbol := range symbols {
+		if _, ok := this.volumes[symbol]; !ok {
+			refresh = true
+		}
+	}
+	if len(symbols) == 0 || !refresh {
+		return this.volumes
+	}
+	// the volumes are not refreshed again in the slot even if the request fails
+	this.volumeTime = now
+	tickers, err := this.Tickers24hr(ctx, symbols)
+	if err != nil {
+		logs.Warn("There is no coin volume in Binance, err: %v", err)
+		return this.volumes
+	}
+	volumes := make(map[string]decimal.Decimal, len(symbols))
+	for _, symbol := range symbols {
+		volumes[symbol] = decimal.Zero
+	}
+	for _, ticker := range tickers {
+		volumes[ticker.Symbol] = ticker.QuoteVolume
+	}
+	this.volumes = volumes
+	return this.volumes
+}
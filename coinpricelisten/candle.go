@@ -0,0 +1,159 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+package coinpricelisten
+
+import (
+	"github.com/astaxie/beego/logs"
+	"price_notify/models"
+	"sync"
+)
+
+var (
+	CANDLE_PERIOD_MINUTE      = int64(60)
+	CANDLE_PERIOD_FIVE_MINUTE = int64(300)
+	CANDLE_PERIOD_HOUR        = int64(3600)
+	CANDLE_PERIOD_DAY         = int64(86400)
+	CANDLE_PERIODS            = []int64{CANDLE_PERIOD_MINUTE, CANDLE_PERIOD_FIVE_MINUTE, CANDLE_PERIOD_HOUR, CANDLE_PERIOD_DAY}
+)
+
+type candleKey struct {
+	tokenName string
+	period    int64
+}
+
+// CandleBuilder builds the candles of tokens from their prices. The latest candle of a token and period is kept
+// in memory until a price of the next period comes, and then it is closed together with the gap candles of the
+// periods without price between them.
+type CandleBuilder struct {
+	periods []int64
+	tails   map[candleKey]*models.PriceCandle
+	lock    sync.RWMutex
+}
+
+func NewCandleBuilder(periods []int64) *CandleBuilder {
+	return &CandleBuilder{
+		periods: periods,
+		tails:   make(map[candleKey]*models.PriceCandle),
+	}
+}
+
+// AddPrice adds the price of token at time to the latest candles, and returns the candles which are closed by it.
+// The price before the latest candle is ignored.
+func (builder *CandleBuilder) AddPrice(tokenName string, price int64, time int64) []*models.PriceCandle {
+	builder.lock.Lock()
+	defer builder.lock.Unlock()
+	closed := make([]*models.PriceCandle, 0)
+	for _, period := range builder.periods {
+		key := candleKey{tokenName: tokenName, period: period}
+		start := time / period * period
+		tail, ok := builder.tails[key]
+		if ok && start < tail.Time {
+			logs.Warn("price of token %s at %d is before the candle at %d of period %d", tokenName, time, tail.Time, period)
+			continue
+		}
+		if ok && start == tail.Time {
+			if price > tail.High {
+				tail.High = price
+			}
+			if price < tail.Low {
+				tail.Low = price
+			}
+			tail.Close = price
+			tail.Count++
+			continue
+		}
+		if ok {
+			closed = append(closed, tail)
+			for gap := tail.Time + period; gap < start; gap += period {
+				closed = append(closed, &models.PriceCandle{
+					TokenBasicName: tokenName,
+					Period:         period,
+					Time:           gap,
+					Open:           tail.Close,
+					High:           tail.Close,
+					Low:            tail.Close,
+					Close:          tail.Close,
+					Gap:            true,
+				})
+			}
+		}
+		builder.tails[key] = &models.PriceCandle{
+			TokenBasicName: tokenName,
+			Period:         period,
+			Time:           start,
+			Open:           price,
+			High:           price,
+			Low:            price,
+			Close:          price,
+			Count:          1,
+		}
+	}
+	return closed
+}
+
+// Tail returns a copy of the latest candle of token and period, nil if there is none.
+func (builder *CandleBuilder) Tail(tokenName string, period int64) *models.PriceCandle {
+	builder.lock.RLock()
+	defer builder.lock.RUnlock()
+	tail, ok := builder.tails[candleKey{tokenName: tokenName, period: period}]
+	if !ok {
+		return nil
+	}
+	candle := *tail
+	return &candle
+}
+
+// Tails returns the copies of the latest candles as partial candles
+func (builder *CandleBuilder) Tails() []*models.PriceCandle {
+	builder.lock.RLock()
+	defer builder.lock.RUnlock()
+	candles := make([]*models.PriceCandle, 0, len(builder.tails))
+	for _, tail := range builder.tails {
+		candle := *tail
+		candle.Partial = true
+		candles = append(candles, &candle)
+	}
+	return candles
+}
+
+// Restore sets the partial candles as the latest candles, the candles of the periods not built are ignored.
+func (builder *CandleBuilder) Restore(candles []*models.PriceCandle) {
+	builder.lock.Lock()
+	defer builder.lock.Unlock()
+	for _, candle := range candles {
+		key := candleKey{tokenName: candle.TokenBasicName, period: candle.Period}
+		if !builder.hasPeriod(candle.Period) {
+			continue
+		}
+		if tail, ok := builder.tails[key]; ok && tail.Time >= candle.Time {
+			continue
+		}
+		tail := *candle
+		tail.Id = 0
+		tail.Partial = false
+		builder.tails[key] = &tail
+	}
+}
+
+func (builder *CandleBuilder) hasPeriod(period int64) bool {
+	for _, item := range builder.periods {
+		if item == period {
+			return true
+		}
+	}
+	return false
+}
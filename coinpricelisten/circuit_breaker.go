@@ -0,0 +1,126 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+package coinpricelisten
+
+import (
+	"github.com/astaxie/beego/logs"
+	"math"
+	"price_notify/basedef"
+	"price_notify/models"
+)
+
+var (
+	// a price jumping from the last one is held
+	PRICE_EVENT_HELD = "held"
+	// a held price is confirmed by consecutive updates or the other sources, and is published
+	PRICE_EVENT_CONFIRMED = "confirmed"
+	// a held price is dropped since the price goes back or jumps to another one
+	PRICE_EVENT_DISCARDED = "discarded"
+)
+
+// PriceEvent reports the price update held by the jump circuit breaker
+type PriceEvent struct {
+	Type      string
+	TokenName string
+	// the last published price
+	Price int64
+	// the held price
+	PendingPrice int64
+	// percent of change from the last published price
+	Change float64
+	// consecutive updates of the held price
+	Ticks int64
+	// agreeing sources of the held price in the last update
+	Sources int64
+	Time    int64
+}
+
+type pendingPrice struct {
+	price int64
+	ticks int64
+}
+
+// RegisterEventHandler adds a handler which is called with the events of held prices.
+func (cpl *CoinPriceListen) RegisterEventHandler(handler func(*PriceEvent)) {
+	cpl.eventHandlers = append(cpl.eventHandlers, handler)
+}
+
+func (cpl *CoinPriceListen) emitEvent(event *PriceEvent) {
+	logs.Warn("price of token %s is %s, price: %d, pending price: %d, change: %.2f%%, ticks: %d, sources: %d",
+		event.TokenName, event.Type, event.Price, event.PendingPrice, event.Change, event.Ticks, event.Sources)
+	for _, handler := range cpl.eventHandlers {
+		handler(event)
+	}
+}
+
+// jumpPercent returns the percent of change from price to newPrice
+func jumpPercent(price int64, newPrice int64) float64 {
+	return math.Abs(float64(newPrice-price)) * 100 / float64(price)
+}
+
+// confirmPrice tells if the aggregated price of token can be published. A price changing from the last published
+// one by more than JumpPercent is held, until it is seen in JumpConfirmTicks consecutive updates or at least 2
+// sources used by the aggregation agree with it.
+func (cpl *CoinPriceListen) confirmPrice(tokenBasic *models.TokenBasic, price int64, now int64) bool {
+	limit := cpl.aggregateCfg.JumpPercent
+	pending, held := cpl.pendingPrices[tokenBasic.Name]
+	if limit <= 0 || tokenBasic.Price <= 0 || jumpPercent(tokenBasic.Price, price) <= limit {
+		if held {
+			delete(cpl.pendingPrices, tokenBasic.Name)
+			cpl.emitEvent(cpl.newPriceEvent(PRICE_EVENT_DISCARDED, tokenBasic, pending, 0, now))
+		}
+		return true
+	}
+	if held && jumpPercent(pending.price, price) > limit {
+		delete(cpl.pendingPrices, tokenBasic.Name)
+		cpl.emitEvent(cpl.newPriceEvent(PRICE_EVENT_DISCARDED, tokenBasic, pending, 0, now))
+		held = false
+	}
+	if !held {
+		pending = &pendingPrice{}
+		cpl.pendingPrices[tokenBasic.Name] = pending
+	}
+	pending.price = price
+	pending.ticks++
+	sources := int64(0)
+	for _, tokenPrice := range tokenBasic.PriceMarkets {
+		if tokenPrice.PriceInd == basedef.PRICE_IND_FRESH && tokenPrice.ExcludeReason == "" &&
+			jumpPercent(price, tokenPrice.Price) <= limit {
+			sources++
+		}
+	}
+	if pending.ticks >= cpl.aggregateCfg.JumpConfirmTicks || sources >= 2 {
+		delete(cpl.pendingPrices, tokenBasic.Name)
+		cpl.emitEvent(cpl.newPriceEvent(PRICE_EVENT_CONFIRMED, tokenBasic, pending, sources, now))
+		return true
+	}
+	cpl.emitEvent(cpl.newPriceEvent(PRICE_EVENT_HELD, tokenBasic, pending, sources, now))
+	return false
+}
+
+func (cpl *CoinPriceListen) newPriceEvent(eventType string, tokenBasic *models.TokenBasic, pending *pendingPrice, sources int64, now int64) *PriceEvent {
+	return &PriceEvent{
+		Type:         eventType,
+		TokenName:    tokenBasic.Name,
+		Price:        tokenBasic.Price,
+		PendingPrice: pending.price,
+		Change:       jumpPercent(tokenBasic.Price, pending.price),
+		Ticks:        pending.ticks,
+		Sources:      sources,
+		Time:         now,
+	}
+}
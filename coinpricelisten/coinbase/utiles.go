@@ -0,0 +1,177 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package coinbase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/astaxie/beego/logs"
+	"io/ioutil"
+	"net/http"
+	"price_notify/basedef"
+	"price_notify/conf"
+	"price_notify/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// coinbase has no all-tickers api, at most MAX_CONCURRENT_REQUESTS product tickers are requested at the same time
+const MAX_CONCURRENT_REQUESTS = 5
+
+type CoinbaseSdk struct {
+	client *http.Client
+	nodes  []*conf.Restful
+}
+
+func DefaultCoinbaseSdk() *CoinbaseSdk {
+	client := &http.Client{}
+	sdk := &CoinbaseSdk{
+		client: client,
+		nodes: []*conf.Restful{
+			{
+				Url: "https://api.exchange.coinbase.com/",
+			},
+		},
+	}
+	return sdk
+}
+
+func NewCoinbaseSdk(cfg *conf.CoinPriceListenConfig) *CoinbaseSdk {
+	client := &http.Client{}
+	sdk := &CoinbaseSdk{
+		client: client,
+		nodes:  cfg.Nodes,
+	}
+	return sdk
+}
+
+// PartialError reports the coins whose price can not be got while the others are returned
+type PartialError struct {
+	Failed map[string]error
+}
+
+func (err *PartialError) Error() string {
+	coins := make([]string, 0, len(err.Failed))
+	for coin := range err.Failed {
+		coins = append(coins, coin)
+	}
+	sort.Strings(coins)
+	failed := make([]string, 0, len(coins))
+	for _, coin := range coins {
+		failed = append(failed, fmt.Sprintf("%s: %v", coin, err.Failed[coin]))
+	}
+	return fmt.Sprintf("Cannot get Coinbase price of %d coins, %s", len(coins), strings.Join(failed, "; "))
+}
+
+func (sdk *CoinbaseSdk) ProductTicker(ctx context.Context, product string) (*Ticker, error) {
+	for i := 0; i < len(sdk.nodes); i++ {
+		ticker, err := sdk.productTicker(ctx, product, i)
+		if err != nil {
+			logs.Error("Coinbase ProductTicker %s err: %s", product, err.Error())
+			continue
+		} else {
+			return ticker, nil
+		}
+	}
+	return nil, fmt.Errorf("Cannot get Coinbase ProductTicker of %s!", product)
+}
+
+func (sdk *CoinbaseSdk) productTicker(ctx context.Context, product string, node int) (*Ticker, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", sdk.nodes[node].Url+"products/"+product+"/ticker", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accepts", "application/json")
+
+	resp, err := sdk.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		var body ErrorMedia
+		json.Unmarshal(respBody, &body)
+		return nil, fmt.Errorf("response status code: %d, message: %s", resp.StatusCode, body.Message)
+	}
+	ticker := new(Ticker)
+	err = json.Unmarshal(respBody, ticker)
+	if err != nil {
+		return nil, err
+	}
+	return ticker, nil
+}
+
+func (sdk *CoinbaseSdk) GetMarketName() string {
+	return basedef.MARKET_COINBASE
+}
+
+// NormalizeProduct converts a configured coin name such as "btc/usd" or "BTC_USD"
+// to the product id used by coinbase, e.g. "BTC-USD".
+func NormalizeProduct(coin string) string {
+	product := strings.ToUpper(strings.TrimSpace(coin))
+	return strings.NewReplacer("/", "-", "_", "-").Replace(product)
+}
+
+// GetCoinPrice requests the ticker of every coin with bounded concurrency. The prices which are got
+// are returned even if some coins failed, the failed coins are reported by a *PartialError.
+func (sdk *CoinbaseSdk) GetCoinPrice(ctx context.Context, coins []string) (map[string]*models.CoinPrice, error) {
+	type tickerResult struct {
+		coin   string
+		ticker *Ticker
+		err    error
+	}
+	results := make(chan *tickerResult, len(coins))
+	limit := make(chan struct{}, MAX_CONCURRENT_REQUESTS)
+	wg := new(sync.WaitGroup)
+	for _, coin := range coins {
+		wg.Add(1)
+		go func(coin string) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			ticker, err := sdk.ProductTicker(ctx, NormalizeProduct(coin))
+			results <- &tickerResult{coin: coin, ticker: ticker, err: err}
+		}(coin)
+	}
+	wg.Wait()
+	close(results)
+	coinPrice := make(map[string]*models.CoinPrice, 0)
+	failed := make(map[string]error, 0)
+	for result := range results {
+		if result.err != nil {
+			failed[result.coin] = result.err
+			continue
+		}
+		ticker := result.ticker
+		tickerTime, err := time.Parse(time.RFC3339Nano, ticker.Time)
+		if err != nil {
+			tickerTime = time.Now()
+		}
+		// volume of coinbase is in base currency
+		coinPrice[result.coin] = &models.CoinPrice{Price: ticker.Price, Volume: ticker.Volume.Mul(ticker.Price), Time: tickerTime.Unix()}
+	}
+	if len(failed) > 0 {
+		return coinPrice, &PartialError{Failed: failed}
+	}
+	return coinPrice, nil
+}
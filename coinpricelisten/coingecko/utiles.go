@@ -0,0 +1,309 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package coingecko
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/astaxie/beego/logs"
+	"github.com/shopspring/decimal"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"price_notify/basedef"
+	"price_notify/conf"
+	"price_notify/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// the ids of coins is sent in url, at most MAX_IDS_LENGTH bytes for one request
+	MAX_IDS_LENGTH = 1500
+	// the coin list is refreshed once a day by default
+	DEFAULT_CACHE_REFRESH_SLOT = int64(86400)
+)
+
+type CoinGeckoSdk struct {
+	client           *http.Client
+	nodes            []*conf.Restful
+	cacheFile        string
+	cacheRefreshSlot int64
+	cache            *CoinsCache
+	coinName2Id      map[string]string
+	cacheLock        sync.Mutex
+}
+
+func DefaultCoinGeckoSdk() *CoinGeckoSdk {
+	client := &http.Client{}
+	sdk := &CoinGeckoSdk{
+		client: client,
+		nodes: []*conf.Restful{
+			{
+				Url: "https://api.coingecko.com/api/v3/",
+			},
+		},
+		cacheRefreshSlot: DEFAULT_CACHE_REFRESH_SLOT,
+	}
+	return sdk
+}
+
+func NewCoinGeckoSdk(cfg *conf.CoinPriceListenConfig) *CoinGeckoSdk {
+	client := &http.Client{}
+	sdk := &CoinGeckoSdk{
+		client:           client,
+		nodes:            cfg.Nodes,
+		cacheFile:        cfg.CacheFile,
+		cacheRefreshSlot: cfg.CacheRefreshSlot,
+	}
+	if sdk.cacheRefreshSlot <= 0 {
+		sdk.cacheRefreshSlot = DEFAULT_CACHE_REFRESH_SLOT
+	}
+	return sdk
+}
+
+func (sdk *CoinGeckoSdk) CoinsList(ctx context.Context) ([]*Coin, error) {
+	for i := 0; i < len(sdk.nodes); i++ {
+		coins, err := sdk.coinsList(ctx, i)
+		if err != nil {
+			logs.Error("CoinGecko CoinsList err: %s", err.Error())
+			continue
+		} else {
+			return coins, nil
+		}
+	}
+	return nil, fmt.Errorf("Cannot get CoinGecko CoinsList!")
+}
+
+func (sdk *CoinGeckoSdk) coinsList(ctx context.Context, node int) ([]*Coin, error) {
+	coins := make([]*Coin, 0)
+	err := sdk.request(ctx, node, "coins/list", nil, &coins)
+	if err != nil {
+		return nil, err
+	}
+	return coins, nil
+}
+
+func (sdk *CoinGeckoSdk) SimplePrice(ctx context.Context, ids string) (map[string]map[string]decimal.Decimal, error) {
+	for i := 0; i < len(sdk.nodes); i++ {
+		prices, err := sdk.simplePrice(ctx, ids, i)
+		if err != nil {
+			logs.Error("CoinGecko SimplePrice err: %s", err.Error())
+			continue
+		} else {
+			return prices, nil
+		}
+	}
+	return nil, fmt.Errorf("Cannot get CoinGecko SimplePrice!")
+}
+
+func (sdk *CoinGeckoSdk) simplePrice(ctx context.Context, ids string, node int) (map[string]map[string]decimal.Decimal, error) {
+	q := url.Values{}
+	q.Add("ids", ids)
+	q.Add("vs_currencies", "usd")
+	q.Add("include_24hr_vol", "true")
+	q.Add("include_last_updated_at", "true")
+	prices := make(map[string]map[string]decimal.Decimal)
+	err := sdk.request(ctx, node, "simple/price", q, &prices)
+	if err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+func (sdk *CoinGeckoSdk) request(ctx context.Context, node int, path string, q url.Values, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", sdk.nodes[node].Url+path, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accepts", "application/json")
+	if sdk.nodes[node].Key != "" {
+		req.Header.Add("x-cg-pro-api-key", sdk.nodes[node].Key)
+	}
+	if q != nil {
+		req.URL.RawQuery = q.Encode()
+	}
+
+	resp, err := sdk.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("response status code: %d", resp.StatusCode)
+	}
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	return json.Unmarshal(respBody, result)
+}
+
+// getCoinName2Id returns the coin name and id to id mapping. The coin list is read from the cache file
+// when the sdk starts and is refreshed from coingecko once it is older than cacheRefreshSlot, the old
+// list is still used if the refreshing failed.
+func (sdk *CoinGeckoSdk) getCoinName2Id(ctx context.Context) (map[string]string, error) {
+	sdk.cacheLock.Lock()
+	defer sdk.cacheLock.Unlock()
+	if sdk.cache == nil && sdk.cacheFile != "" {
+		cache, err := loadCoinsCache(sdk.cacheFile)
+		if err != nil {
+			logs.Warn("load CoinGecko coins cache err: %v", err)
+		} else {
+			sdk.setCache(cache)
+		}
+	}
+	now := time.Now().Unix()
+	if sdk.cache == nil || sdk.cache.Time+sdk.cacheRefreshSlot <= now {
+		coins, err := sdk.CoinsList(ctx)
+		if err != nil {
+			if sdk.cache == nil {
+				return nil, err
+			}
+			logs.Warn("refresh CoinGecko coins err: %v, use the coins of %d", err, sdk.cache.Time)
+			return sdk.coinName2Id, nil
+		}
+		sdk.setCache(&CoinsCache{Time: now, Coins: coins})
+		if sdk.cacheFile != "" {
+			err = saveCoinsCache(sdk.cacheFile, sdk.cache)
+			if err != nil {
+				logs.Warn("save CoinGecko coins cache err: %v", err)
+			}
+		}
+	}
+	return sdk.coinName2Id, nil
+}
+
+func (sdk *CoinGeckoSdk) setCache(cache *CoinsCache) {
+	coinName2Id := make(map[string]string)
+	duplicated := make(map[string]bool)
+	for _, coin := range cache.Coins {
+		name := strings.ToLower(coin.Name)
+		if _, ok := coinName2Id[name]; ok {
+			duplicated[name] = true
+		}
+		coinName2Id[name] = coin.Id
+	}
+	for name := range duplicated {
+		delete(coinName2Id, name)
+	}
+	for _, coin := range cache.Coins {
+		coinName2Id[coin.Id] = coin.Id
+	}
+	sdk.cache = cache
+	sdk.coinName2Id = coinName2Id
+}
+
+func loadCoinsCache(fileName string) (*CoinsCache, error) {
+	data, err := basedef.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	cache := new(CoinsCache)
+	err = json.Unmarshal(data, cache)
+	if err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+func saveCoinsCache(fileName string, cache *CoinsCache) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+// ChunkIds splits ids to groups, the joined ids of every group is not longer than maxLength.
+func ChunkIds(ids []string, maxLength int) [][]string {
+	chunks := make([][]string, 0)
+	chunk := make([]string, 0)
+	length := 0
+	for _, id := range ids {
+		if len(chunk) > 0 && length+1+len(id) > maxLength {
+			chunks = append(chunks, chunk)
+			chunk = make([]string, 0)
+			length = 0
+		}
+		if len(chunk) > 0 {
+			length++
+		}
+		chunk = append(chunk, id)
+		length += len(id)
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func (sdk *CoinGeckoSdk) GetMarketName() string {
+	return basedef.MARKET_COINGECKO
+}
+
+func (sdk *CoinGeckoSdk) GetCoinPrice(ctx context.Context, coins []string) (map[string]*models.CoinPrice, error) {
+	coinName2Id, err := sdk.getCoinName2Id(ctx)
+	if err != nil {
+		return nil, err
+	}
+	//
+	id2Coins := make(map[string][]string, 0)
+	ids := make([]string, 0)
+	for _, coin := range coins {
+		id, ok := coinName2Id[strings.ToLower(strings.TrimSpace(coin))]
+		if !ok {
+			logs.Warn("There is no coin %s in CoinGecko!", coin)
+			continue
+		}
+		if _, ok := id2Coins[id]; !ok {
+			ids = append(ids, id)
+		}
+		id2Coins[id] = append(id2Coins[id], coin)
+	}
+	//
+	coinPrice := make(map[string]*models.CoinPrice, 0)
+	failed := make([]string, 0)
+	for _, chunk := range ChunkIds(ids, MAX_IDS_LENGTH) {
+		prices, err := sdk.SimplePrice(ctx, strings.Join(chunk, ","))
+		if err != nil {
+			// the prices of the other chunks are kept
+			logs.Error("There is no price for coins %s in CoinGecko: %v", strings.Join(chunk, ","), err)
+			failed = append(failed, chunk...)
+			continue
+		}
+		for id, price := range prices {
+			usd, ok := price["usd"]
+			if !ok {
+				logs.Warn("There is no price for coin %s in CoinGecko!", id)
+				continue
+			}
+			updatedAt := price["last_updated_at"].IntPart()
+			if updatedAt == 0 {
+				updatedAt = time.Now().Unix()
+			}
+			for _, coin := range id2Coins[id] {
+				coinPrice[coin] = &models.CoinPrice{Price: usd, Volume: price["usd_24h_vol"], Time: updatedAt}
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return coinPrice, fmt.Errorf("Cannot get CoinGecko SimplePrice of %s!", strings.Join(failed, ","))
+	}
+	return coinPrice, nil
+}
@@ -17,12 +17,14 @@
 
 package coinmarketcap
 
+import "github.com/shopspring/decimal"
+
 // Listing struct
 type Listing struct {
 	ID     int    `json:"id"`
 	Name   string `json:"name"`
 	Symbol string `json:"symbol"`
-	Slug   string `json:"website_slug"`
+	Slug   string `json:"slug"`
 }
 
 // Ticker struct
@@ -30,7 +32,7 @@ type Ticker struct {
 	ID                int                     `json:"id"`
 	Name              string                  `json:"name"`
 	Symbol            string                  `json:"symbol"`
-	Slug              string                  `json:"website_slug"`
+	Slug              string                  `json:"slug"`
 	Rank              int                     `json:"rank"`
 	CirculatingSupply float64                 `json:"circulating_supply"`
 	TotalSupply       float64                 `json:"total_supply"`
@@ -41,10 +43,26 @@ type Ticker struct {
 
 // TickerQuote struct
 type TickerQuote struct {
-	Price            float64 `json:"price"`
-	Volume24H        float64 `json:"volume_24h"`
-	MarketCap        float64 `json:"market_cap"`
-	PercentChange1H  float64 `json:"percent_change_1h"`
-	PercentChange24H float64 `json:"percent_change_24h"`
-	PercentChange7D  float64 `json:"percent_change_7d"`
+	Price            decimal.Decimal `json:"price"`
+	Volume24H        decimal.Decimal `json:"volume_24h"`
+	MarketCap        decimal.Decimal `json:"market_cap"`
+	PercentChange1H  float64         `json:"percent_change_1h"`
+	PercentChange24H float64         `json:"percent_change_24h"`
+	PercentChange7D  float64         `json:"percent_change_7d"`
+}
+
+// ListingsCache is the listings saved in the cache file
+type ListingsCache struct {
+	Time     int64
+	Listings []*Listing
+}
+
+// CacheStats struct
+type CacheStats struct {
+	Entries       int
+	LastRefresh   int64
+	Refreshes     int64
+	RefreshErrors int64
+	Hits          int64
+	Misses        int64
 }
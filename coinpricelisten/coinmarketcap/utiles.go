@@ -18,6 +18,7 @@
 package coinmarketcap
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/astaxie/beego/logs"
@@ -26,12 +27,30 @@ import (
 	"net/url"
 	"price_notify/basedef"
 	"price_notify/conf"
+	"price_notify/models"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// the listings is refreshed once a day by default
+	DEFAULT_CACHE_REFRESH_SLOT = int64(86400)
+	// the listings is refreshed at most once in MISS_REFRESH_SLOT when some coins is missed
+	MISS_REFRESH_SLOT = int64(600)
 )
 
 type CoinMarketCapSdk struct {
-	client *http.Client
-	nodes  []*conf.Restful
+	client           *http.Client
+	nodes            []*conf.Restful
+	cacheFile        string
+	cacheRefreshSlot int64
+	cache            *ListingsCache
+	index            *listingIndex
+	cacheStats       CacheStats
+	cacheLock        sync.Mutex
 }
 
 func DefaultCoinMarketCapSdk() *CoinMarketCapSdk {
@@ -44,6 +63,7 @@ func DefaultCoinMarketCapSdk() *CoinMarketCapSdk {
 				Key: "8efe5156-8b37-4c77-8e1d-a140c97bf466",
 			},
 		},
+		cacheRefreshSlot: DEFAULT_CACHE_REFRESH_SLOT,
 	}
 	return sdk
 }
@@ -51,8 +71,13 @@ func DefaultCoinMarketCapSdk() *CoinMarketCapSdk {
 func NewCoinMarketCapSdk(cfg *conf.CoinPriceListenConfig) *CoinMarketCapSdk {
 	client := &http.Client{}
 	sdk := &CoinMarketCapSdk{
-		client: client,
-		nodes:  cfg.Nodes,
+		client:           client,
+		nodes:            cfg.Nodes,
+		cacheFile:        cfg.CacheFile,
+		cacheRefreshSlot: cfg.CacheRefreshSlot,
+	}
+	if sdk.cacheRefreshSlot <= 0 {
+		sdk.cacheRefreshSlot = DEFAULT_CACHE_REFRESH_SLOT
 	}
 	return sdk
 }
@@ -61,9 +86,9 @@ type ListingsMedia struct {
 	Data []*Listing `json:"data"`
 }
 
-func (sdk *CoinMarketCapSdk) ListingsLatest() ([]*Listing, error) {
+func (sdk *CoinMarketCapSdk) ListingsLatest(ctx context.Context) ([]*Listing, error) {
 	for i := 0; i < len(sdk.nodes); i++ {
-		listings, err := sdk.listingsLatest(i)
+		listings, err := sdk.listingsLatest(ctx, i)
 		if err != nil {
 			logs.Error("CoinMarketCap ListingsLatest err: %s", err.Error())
 			continue
@@ -74,8 +99,8 @@ func (sdk *CoinMarketCapSdk) ListingsLatest() ([]*Listing, error) {
 	return nil, fmt.Errorf("Cannot get CoinMarketCap ListingsLatest!")
 }
 
-func (sdk *CoinMarketCapSdk) listingsLatest(node int) ([]*Listing, error) {
-	req, err := http.NewRequest("GET", sdk.nodes[node].Url+"listings/latest", nil)
+func (sdk *CoinMarketCapSdk) listingsLatest(ctx context.Context, node int) ([]*Listing, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", sdk.nodes[node].Url+"listings/latest", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -110,9 +135,9 @@ type QuotesLatestMedia struct {
 	Data map[string]*Ticker `json:"data"`
 }
 
-func (sdk *CoinMarketCapSdk) QuotesLatest(coins string) (map[string]*Ticker, error) {
+func (sdk *CoinMarketCapSdk) QuotesLatest(ctx context.Context, coins string) (map[string]*Ticker, error) {
 	for i := 0; i < len(sdk.nodes); i++ {
-		quotes, err := sdk.quotesLatest(coins, i)
+		quotes, err := sdk.quotesLatest(ctx, coins, i)
 		if err != nil {
 			logs.Error("CoinMarketCap QuotesLatest err: %s", err.Error())
 			continue
@@ -123,8 +148,8 @@ func (sdk *CoinMarketCapSdk) QuotesLatest(coins string) (map[string]*Ticker, err
 	return nil, fmt.Errorf("Cannot get CoinMarketCap QuotesLatest!")
 }
 
-func (sdk *CoinMarketCapSdk) quotesLatest(coins string, node int) (map[string]*Ticker, error) {
-	req, err := http.NewRequest("GET", sdk.nodes[node].Url+"quotes/latest", nil)
+func (sdk *CoinMarketCapSdk) quotesLatest(ctx context.Context, coins string, node int) (map[string]*Ticker, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", sdk.nodes[node].Url+"quotes/latest", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -158,41 +183,224 @@ func (sdk *CoinMarketCapSdk) GetMarketName() string {
 	return basedef.MARKET_COINMARKETCAP
 }
 
-func (sdk *CoinMarketCapSdk) GetCoinPrice(coins []string) (map[string]float64, error) {
-	listings, err := sdk.ListingsLatest()
+// getListingIndex returns the index of listings. The listings is read from the cache file when
+// the sdk starts and is refreshed from coinmarketcap once it is older than cacheRefreshSlot, or when
+// some coins is missed and the listings is older than MISS_REFRESH_SLOT. The old listings is still used
+// if the refreshing failed.
+func (sdk *CoinMarketCapSdk) getListingIndex(ctx context.Context, missed bool) (*listingIndex, error) {
+	sdk.cacheLock.Lock()
+	defer sdk.cacheLock.Unlock()
+	if sdk.cache == nil && sdk.cacheFile != "" {
+		cache, err := loadListingsCache(sdk.cacheFile)
+		if err != nil {
+			logs.Warn("load CoinMarketCap listings cache err: %v", err)
+		} else {
+			sdk.setCache(cache)
+		}
+	}
+	now := time.Now().Unix()
+	refresh := sdk.cache == nil || sdk.cache.Time+sdk.cacheRefreshSlot <= now
+	if missed && sdk.cache != nil && sdk.cache.Time+MISS_REFRESH_SLOT <= now {
+		refresh = true
+	}
+	if refresh {
+		listings, err := sdk.ListingsLatest(ctx)
+		if err != nil {
+			sdk.cacheStats.RefreshErrors++
+			if sdk.cache == nil {
+				return nil, err
+			}
+			logs.Warn("refresh CoinMarketCap listings err: %v, use the listings of %d", err, sdk.cache.Time)
+			return sdk.index, nil
+		}
+		sdk.cacheStats.Refreshes++
+		sdk.setCache(&ListingsCache{Time: now, Listings: listings})
+		if sdk.cacheFile != "" {
+			err = saveListingsCache(sdk.cacheFile, sdk.cache)
+			if err != nil {
+				logs.Warn("save CoinMarketCap listings cache err: %v", err)
+			}
+		}
+	}
+	return sdk.index, nil
+}
+
+func (sdk *CoinMarketCapSdk) setCache(cache *ListingsCache) {
+	sdk.cache = cache
+	sdk.index = newListingIndex(cache.Listings)
+}
+
+// the prefixes of coin selector, a coin without prefix is selected by the name of listing
+const (
+	SELECTOR_ID     = "id:"
+	SELECTOR_SLUG   = "slug:"
+	SELECTOR_SYMBOL = "symbol:"
+)
+
+type listingIndex struct {
+	name2Id   map[string]string
+	slug2Id   map[string]string
+	symbol2Id map[string][]string
+}
+
+func newListingIndex(listings []*Listing) *listingIndex {
+	index := &listingIndex{
+		name2Id:   make(map[string]string, 0),
+		slug2Id:   make(map[string]string, 0),
+		symbol2Id: make(map[string][]string, 0),
+	}
+	for _, listing := range listings {
+		id := fmt.Sprintf("%d", listing.ID)
+		index.name2Id[listing.Name] = id
+		if listing.Slug != "" {
+			index.slug2Id[strings.ToLower(listing.Slug)] = id
+		}
+		symbol := strings.ToUpper(listing.Symbol)
+		index.symbol2Id[symbol] = append(index.symbol2Id[symbol], id)
+	}
+	return index
+}
+
+// resolve returns the coinmarketcap id of coin, the coin can be "id:1027", "slug:ethereum", "symbol:ETH"
+// or the name of listing such as "Ethereum". The id is returned as empty if the coin is not in the listings.
+func (index *listingIndex) resolve(coin string) (string, error) {
+	if strings.HasPrefix(coin, SELECTOR_ID) {
+		id := strings.TrimSpace(strings.TrimPrefix(coin, SELECTOR_ID))
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			return "", fmt.Errorf("invalid coin id of %s", coin)
+		}
+		return id, nil
+	} else if strings.HasPrefix(coin, SELECTOR_SLUG) {
+		slug := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(coin, SELECTOR_SLUG)))
+		return index.slug2Id[slug], nil
+	} else if strings.HasPrefix(coin, SELECTOR_SYMBOL) {
+		symbol := strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(coin, SELECTOR_SYMBOL)))
+		ids := index.symbol2Id[symbol]
+		if len(ids) > 1 {
+			return "", fmt.Errorf("symbol %s is ambiguous, it is used by coins %s, select the coin by id or slug",
+				symbol, strings.Join(ids, ","))
+		}
+		if len(ids) == 0 {
+			return "", nil
+		}
+		return ids[0], nil
+	} else {
+		return index.name2Id[coin], nil
+	}
+}
+
+func loadListingsCache(fileName string) (*ListingsCache, error) {
+	data, err := basedef.ReadFile(fileName)
 	if err != nil {
 		return nil, err
 	}
-	//
-	coinName2Id := make(map[string]string, 0)
-	for _, listing := range listings {
-		coinName2Id[listing.Name] = fmt.Sprintf("%d", listing.ID)
+	cache := new(ListingsCache)
+	err = json.Unmarshal(data, cache)
+	if err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+func saveListingsCache(fileName string, cache *ListingsCache) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, data, 0644)
+}
+
+// CacheStats returns the statistics of the listings cache
+func (sdk *CoinMarketCapSdk) CacheStats() *CacheStats {
+	sdk.cacheLock.Lock()
+	defer sdk.cacheLock.Unlock()
+	stats := sdk.cacheStats
+	if sdk.cache != nil {
+		stats.Entries = len(sdk.cache.Listings)
+		stats.LastRefresh = sdk.cache.Time
+	}
+	return &stats
+}
+
+func (sdk *CoinMarketCapSdk) lookupCoinIds(ctx context.Context, coins []string, missed bool) (map[string][]string, []string, map[string]error, error) {
+	index, err := sdk.getListingIndex(ctx, missed)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	//
-	coinIds := make([]string, 0)
+	id2Coins := make(map[string][]string, 0)
+	missedCoins := make([]string, 0)
+	failedCoins := make(map[string]error, 0)
 	for _, coin := range coins {
-		coinId, ok := coinName2Id[coin]
-		if !ok {
-			logs.Warn("There is no coin %s in CoinMarketCap!", coin)
+		coinId, err := index.resolve(coin)
+		if err != nil {
+			failedCoins[coin] = err
+			continue
+		}
+		if coinId == "" {
+			missedCoins = append(missedCoins, coin)
 			continue
 		}
-		coinIds = append(coinIds, coinId)
+		id2Coins[coinId] = append(id2Coins[coinId], coin)
 	}
-	//
-	requestCoinIds := strings.Join(coinIds, ",")
-	quotes, err := sdk.QuotesLatest(requestCoinIds)
+	return id2Coins, missedCoins, failedCoins, nil
+}
+
+// GetCoinPrice returns the prices of coins which are selected by id, slug, symbol or name. The coins
+// which are missed or ambiguous are reported by the error while the other prices are still returned.
+func (sdk *CoinMarketCapSdk) GetCoinPrice(ctx context.Context, coins []string) (map[string]*models.CoinPrice, error) {
+	id2Coins, missedCoins, failedCoins, err := sdk.lookupCoinIds(ctx, coins, false)
 	if err != nil {
 		return nil, err
 	}
-	//
-	coinName2Price := make(map[string]float64)
-	for _, v := range quotes {
-		name := v.Name
-		if v.Quote == nil || v.Quote["USD"] == nil {
-			logs.Warn(" There is no price for coin %s in CoinMarketCap!", name)
-			continue
+	if len(missedCoins) > 0 {
+		id2Coins, missedCoins, failedCoins, err = sdk.lookupCoinIds(ctx, coins, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sdk.cacheLock.Lock()
+	sdk.cacheStats.Hits += int64(len(coins) - len(missedCoins) - len(failedCoins))
+	sdk.cacheStats.Misses += int64(len(missedCoins))
+	sdk.cacheLock.Unlock()
+	for _, coin := range missedCoins {
+		logs.Warn("There is no coin %s in CoinMarketCap!", coin)
+		failedCoins[coin] = fmt.Errorf("there is no coin %s", coin)
+	}
+	coinPrice := make(map[string]*models.CoinPrice)
+	if len(id2Coins) > 0 {
+		coinIds := make([]string, 0, len(id2Coins))
+		for coinId := range id2Coins {
+			coinIds = append(coinIds, coinId)
+		}
+		//
+		requestCoinIds := strings.Join(coinIds, ",")
+		quotes, err := sdk.QuotesLatest(ctx, requestCoinIds)
+		if err != nil {
+			return nil, err
+		}
+		//
+		for _, v := range quotes {
+			if v.Quote == nil || v.Quote["USD"] == nil {
+				logs.Warn(" There is no price for coin %s in CoinMarketCap!", v.Name)
+				continue
+			}
+			lastUpdated, err := time.Parse(time.RFC3339Nano, v.LastUpdated)
+			if err != nil {
+				lastUpdated = time.Now()
+			}
+			quote := v.Quote["USD"]
+			for _, coin := range id2Coins[fmt.Sprintf("%d", v.ID)] {
+				coinPrice[coin] = &models.CoinPrice{Price: quote.Price, Volume: quote.Volume24H, Time: lastUpdated.Unix()}
+			}
+		}
+	}
+	if len(failedCoins) > 0 {
+		failed := make([]string, 0, len(failedCoins))
+		for coin, err := range failedCoins {
+			failed = append(failed, fmt.Sprintf("%s: %v", coin, err))
 		}
-		coinName2Price[name] = v.Quote["USD"].Price
+		sort.Strings(failed)
+		return coinPrice, fmt.Errorf("Cannot get CoinMarketCap price of %s", strings.Join(failed, "; "))
 	}
-	return coinName2Price, nil
+	return coinPrice, nil
 }
@@ -18,35 +18,37 @@
 package coinpricelisten
 
 import (
+	"context"
+	"fmt"
 	"github.com/astaxie/beego/logs"
-	"math/big"
+	"github.com/shopspring/decimal"
+	"io"
 	"price_notify/basedef"
 	"price_notify/coinpricedao"
-	"price_notify/coinpricelisten/binance"
-	"price_notify/coinpricelisten/coinmarketcap"
 	"price_notify/conf"
 	"price_notify/models"
 	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 )
 
 var cpListen *CoinPriceListen
 
-func StartCoinPriceListen(server string, priceUpdateSlot int64, coinPricecfg []*conf.CoinPriceListenConfig, dbCfg *conf.DBConfig) {
+func StartCoinPriceListen(server string, priceUpdateSlot int64, coinPricecfg []*conf.CoinPriceListenConfig, aggregateCfg *conf.CoinPriceAggregateConfig, historyCfg *conf.PriceHistoryConfig, dbCfg *conf.DBConfig) {
 	dao := coinpricedao.NewCoinPriceDao(server, dbCfg)
 	if dao == nil {
 		panic("server is not valid")
 	}
 	priceMarkets := make([]PriceMarket, 0)
 	for _, cfg := range coinPricecfg {
-		priceMarket := NewPriceMarket(cfg)
-		if priceMarket == nil {
-			panic("price market is not valid")
+		priceMarket, err := NewPriceMarket(cfg)
+		if err != nil {
+			panic(err)
 		}
 		priceMarkets = append(priceMarkets, priceMarket)
 	}
-	cpListen = NewCoinPriceListen(priceUpdateSlot, priceMarkets, dao)
+	cpListen = NewCoinPriceListen(priceUpdateSlot, priceMarkets, aggregateCfg, historyCfg, dao)
 	cpListen.Start()
 }
 
@@ -56,55 +58,60 @@ func StopCoinPriceListen() {
 	}
 }
 
+// PriceMarket query the price, 24h volume and time of coins in a market. GetCoinPrice may return the prices
+// it got together with an error which reports the coins it did not get.
+// The query should be given up when ctx is done.
 type PriceMarket interface {
-	GetCoinPrice(coins []string) (map[string]float64, error)
+	GetCoinPrice(ctx context.Context, coins []string) (map[string]*models.CoinPrice, error)
 	GetMarketName() string
 }
 
-func NewPriceMarket(cfg *conf.CoinPriceListenConfig) PriceMarket {
-	if cfg.MarketName == basedef.MARKET_COINMARKETCAP {
-		return coinmarketcap.NewCoinMarketCapSdk(cfg)
-	} else if cfg.MarketName == basedef.MARKET_BINANCE {
-		return binance.NewBinanceSdk(cfg)
-	} else {
-		return nil
-	}
-}
-
 type CoinPriceListen struct {
 	priceUpdateSlot int64
 	priceMarket     map[string]PriceMarket
+	aggregateCfg    *conf.CoinPriceAggregateConfig
+	historyCfg      *conf.PriceHistoryConfig
+	historyTimes    map[historySource]int64
+	candleBuilder   *CandleBuilder
+	pendingPrices   map[string]*pendingPrice
+	eventHandlers   []func(*PriceEvent)
 	db              coinpricedao.CoinPriceDao
 	exit            chan bool
 }
 
-func NewCoinPriceListen(priceUpdateSlot int64, priceMarkets []PriceMarket, db coinpricedao.CoinPriceDao) *CoinPriceListen {
+func NewCoinPriceListen(priceUpdateSlot int64, priceMarkets []PriceMarket, aggregateCfg *conf.CoinPriceAggregateConfig, historyCfg *conf.PriceHistoryConfig, db coinpricedao.CoinPriceDao) *CoinPriceListen {
 	cpListen := &CoinPriceListen{}
 	cpListen.priceUpdateSlot = priceUpdateSlot
+	cpListen.aggregateCfg = newAggregateConfig(aggregateCfg)
+	cpListen.historyCfg = newHistoryConfig(historyCfg)
+	cpListen.historyTimes = make(map[historySource]int64)
+	cpListen.candleBuilder = NewCandleBuilder(CANDLE_PERIODS)
+	cpListen.pendingPrices = make(map[string]*pendingPrice)
+	cpListen.eventHandlers = make([]func(*PriceEvent), 0)
 	cpListen.db = db
 	cpListen.exit = make(chan bool, 0)
 	cpListen.priceMarket = make(map[string]PriceMarket)
 	for _, market := range priceMarkets {
-		cpListen.priceMarket[market.GetMarketName()] = market
-	}
-	//
-	tokenBasics, err := db.GetTokens()
-	if err != nil {
-		panic(err)
+		cpListen.RegisterPriceQuery(market)
 	}
-	err = cpListen.updateCoinPrice(tokenBasics)
+	// the partial candles saved when the listener stopped are continued
+	candles, err := db.GetPartialPriceCandles()
 	if err != nil {
 		panic(err)
 	}
-	err = db.SavePrices(tokenBasics)
+	cpListen.candleBuilder.Restore(candles)
+	//
+	err = cpListen.UpdatePrice()
 	if err != nil {
 		panic(err)
 	}
 	return cpListen
 }
 
+// RegisterPriceQuery adds a price market, its queries are limited by DEFAULT_MARKET_TIMEOUT if it is not
+// limited already.
 func (cpl *CoinPriceListen) RegisterPriceQuery(priceMarket PriceMarket) {
-	cpl.priceMarket[priceMarket.GetMarketName()] = priceMarket
+	cpl.priceMarket[priceMarket.GetMarketName()] = limitPriceMarket(priceMarket, 0)
 }
 
 func (cpl *CoinPriceListen) Start() {
@@ -114,9 +121,27 @@ func (cpl *CoinPriceListen) Start() {
 
 func (cpl *CoinPriceListen) Stop() {
 	cpl.exit <- true
+	for _, market := range cpl.priceMarket {
+		if closer, ok := market.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+	err := cpl.SaveCandleTails()
+	if err != nil {
+		logs.Error("%v", err)
+	}
 	logs.Info("stop coin price listen.")
 }
 
+// SaveCandleTails saves the latest candles as partial candles, so they are continued after restart.
+func (cpl *CoinPriceListen) SaveCandleTails() error {
+	err := cpl.db.AddPriceCandles(cpl.candleBuilder.Tails())
+	if err != nil {
+		return fmt.Errorf("save partial price candle err: %v", err)
+	}
+	return nil
+}
+
 func (cpl *CoinPriceListen) ListenPrice() {
 	for {
 		exit := cpl.listenPrice()
@@ -138,23 +163,21 @@ func (cpl *CoinPriceListen) listenPrice() (exit bool) {
 
 	logs.Debug("coin price listen, market: %s, dao: %s......", cpl.GetPriceMarket(), cpl.db.Name())
 	ticker := time.NewTicker(time.Second * time.Duration(cpl.priceUpdateSlot))
+	rollupTicker := time.NewTicker(time.Second * time.Duration(cpl.historyCfg.RollupSlot))
 	for {
 		select {
 		case <-ticker.C:
 			logs.Info("do price update at time: %s", time.Now().Format("2006-01-02 15:04:05"))
-			tokenBasics, err := cpl.db.GetTokens()
+			err := cpl.UpdatePrice()
 			if err != nil {
-				logs.Error("get token basic err: %v", err)
+				logs.Error("%v", err)
 				continue
 			}
-			err = cpl.updateCoinPrice(tokenBasics)
-			if err != nil {
-				logs.Error("updateCoinPrice err: %v", err)
-				continue
-			}
-			err = cpl.db.SavePrices(tokenBasics)
+			break
+		case <-rollupTicker.C:
+			err := cpl.RollupPriceHistory(time.Now().Unix())
 			if err != nil {
-				logs.Error("save price err: %v", err)
+				logs.Error("rollup price history err: %v", err)
 				continue
 			}
 			break
@@ -165,71 +188,177 @@ func (cpl *CoinPriceListen) listenPrice() (exit bool) {
 	}
 }
 
-func (cpl *CoinPriceListen) updateCoinPrice(tokenBasics []*models.TokenBasic) error {
+// UpdatePrice updates the prices of all tokens from the markets and saves them.
+func (cpl *CoinPriceListen) UpdatePrice() error {
+	tokenBasics, err := cpl.db.GetTokens()
+	if err != nil {
+		return fmt.Errorf("get token basic err: %v", err)
+	}
+	err = cpl.updateCoinPrice(tokenBasics)
+	if err != nil {
+		return fmt.Errorf("updateCoinPrice err: %v", err)
+	}
+	err = cpl.db.SavePrices(tokenBasics)
+	if err != nil {
+		return fmt.Errorf("save price err: %v", err)
+	}
+	// the candles are built even if the history is not saved
+	historyErr := cpl.db.AddPriceHistories(cpl.newPriceHistories(tokenBasics, time.Now().Unix()))
+	candles := make([]*models.PriceCandle, 0)
+	for _, tokenBasic := range tokenBasics {
+		if tokenBasic.PriceInd == basedef.PRICE_IND_FRESH {
+			candles = append(candles, cpl.candleBuilder.AddPrice(tokenBasic.Name, tokenBasic.Price, tokenBasic.Time)...)
+		}
+	}
+	candleErr := cpl.db.AddPriceCandles(candles)
+	if historyErr != nil {
+		return fmt.Errorf("save price history err: %v", historyErr)
+	}
+	if candleErr != nil {
+		return fmt.Errorf("save price candle err: %v", candleErr)
+	}
+	return nil
+}
+
+// CandleBuilder returns the builder of the candles of token prices
+func (cpl *CoinPriceListen) CandleBuilder() *CandleBuilder {
+	return cpl.candleBuilder
+}
+
+// updateCoinPrice updates the prices of tokens which are not disabled, the prices are scaled by the
+// precision of token.
+func (cpl *CoinPriceListen) updateCoinPrice(allTokenBasics []*models.TokenBasic) error {
+	tokenBasics := make([]*models.TokenBasic, 0, len(allTokenBasics))
+	for _, tokenBasic := range allTokenBasics {
+		if tokenBasic.Property != basedef.TOKEN_PROPERTY_DISABLED {
+			tokenBasics = append(tokenBasics, tokenBasic)
+		}
+	}
 	marketCoins := make(map[string][]string)
-	marketCoinPrices := make(map[string]*models.PriceMarket)
+	marketTokenPrices := make(map[string][]*models.PriceMarket)
+	tokenDecimals := make(map[string]int32)
+	prices := make(map[*models.PriceMarket]decimal.Decimal)
 	for _, tokenBasic := range tokenBasics {
+		tokenDecimals[tokenBasic.Name] = basedef.TokenDecimals(tokenBasic.Precision)
+		tokenBasic.PriceInd = basedef.PRICE_IND_NOT_UPDATED
 		for _, priceMarket := range tokenBasic.PriceMarkets {
 			coins, ok := marketCoins[priceMarket.MarketName]
 			if !ok {
 				coins = make([]string, 0)
-				marketCoins[priceMarket.MarketName] = coins
 			}
-			coins = append(coins, priceMarket.Name)
+			for _, coin := range crossRateCoins(priceMarket.Name) {
+				if !containsCoin(coins, coin) {
+					coins = append(coins, coin)
+				}
+			}
 			marketCoins[priceMarket.MarketName] = coins
-			marketCoinPrices[priceMarket.MarketName+priceMarket.Name] = priceMarket
-			priceMarket.PriceInd = 0
-			tokenBasic.PriceInd = 0
+			marketTokenPrices[priceMarket.MarketName] = append(marketTokenPrices[priceMarket.MarketName], priceMarket)
+			priceMarket.PriceInd = basedef.PRICE_IND_NOT_UPDATED
+			priceMarket.ExcludeReason = ""
 		}
 	}
-	for market, query := range cpl.priceMarket {
-		coins, ok := marketCoins[market]
-		if !ok {
-			logs.Error("there is no coins of market: %s", market)
-			continue
-		}
-		coinPrices, err := query.GetCoinPrice(coins)
+	for market, result := range cpl.fetchCoinPrices(marketCoins) {
+		coinPrices, err := result.coinPrices, result.err
 		if err != nil {
 			logs.Error("get coin price of market: %s err: %v", market, err)
-			continue
+			if len(coinPrices) == 0 {
+				continue
+			}
+		} else {
+			logs.Info("get coin price of market: %s successful", market)
 		}
-		logs.Info("get coin price of market: %s successful", market)
-		for name, price := range coinPrices {
-			tokenPrice := marketCoinPrices[market+name]
+		for _, tokenPrice := range marketTokenPrices[market] {
+			coinPrice, ok := crossRatePrice(tokenPrice.Name, coinPrices)
 			if !ok {
-				logs.Error("there is no coins of market: %s and token: %s", market, name)
+				logs.Error("there is no coins of market: %s and token: %s", market, tokenPrice.Name)
+				continue
+			}
+			decimals, ok := tokenDecimals[tokenPrice.TokenBasicName]
+			if !ok {
+				decimals = basedef.PRICE_DECIMALS
+			}
+			price, err := basedef.PriceToInt64(coinPrice.Price, decimals)
+			if err != nil {
+				logs.Error("price of market: %s and token: %s err: %v", market, tokenPrice.Name, err)
 				continue
 			}
-			price, _ := new(big.Float).Mul(big.NewFloat(price), big.NewFloat(float64(basedef.PRICE_PRECISION))).Int64()
+			volume, err := basedef.PriceToInt64(coinPrice.Volume, 0)
+			if err != nil {
+				logs.Warn("volume of market: %s and token: %s err: %v", market, tokenPrice.Name, err)
+				volume = 0
+			}
+			prices[tokenPrice] = coinPrice.Price
 			tokenPrice.Price = price
-			tokenPrice.Time = time.Now().Unix()
-			tokenPrice.PriceInd = 1
+			tokenPrice.Volume = volume
+			tokenPrice.Time = coinPrice.Time
+			if tokenPrice.Time == 0 {
+				tokenPrice.Time = time.Now().Unix()
+			}
+			tokenPrice.PriceInd = basedef.PRICE_IND_FRESH
 		}
 	}
+	now := time.Now().Unix()
 	for _, tokenBasic := range tokenBasics {
-		price := int64(0)
-		counter := int64(0)
-		for _, tokenPrice := range tokenBasic.PriceMarkets {
-			if tokenPrice.PriceInd == 1 {
-				price += tokenPrice.Price
-				counter++
-			}
+		price, ok := cpl.aggregatePrice(tokenBasic, prices, now)
+		if ok {
+			ok = cpl.confirmPrice(tokenBasic, price, now)
 		}
-		if counter > 0 {
-			price = price / counter
+		if ok {
 			tokenBasic.Price = price
-			tokenBasic.PriceInd = 1
-			tokenBasic.Time = time.Now().Unix()
+			tokenBasic.PriceInd = basedef.PRICE_IND_FRESH
+			tokenBasic.Time = now
 		}
 	}
 	for _, tokenBasic := range tokenBasics {
-		if tokenBasic.PriceInd == 0 {
+		if tokenBasic.PriceInd == basedef.PRICE_IND_FRESH {
+			continue
+		}
+		maxAge := cpl.maxAge(tokenBasic)
+		if maxAge > 0 && now-tokenBasic.Time > maxAge {
+			tokenBasic.PriceInd = basedef.PRICE_IND_STALE
+			logs.Error("Price of token %s is stale, last update at %d", tokenBasic.Name, tokenBasic.Time)
+		} else {
 			logs.Error("Price of token %s is not update", tokenBasic.Name)
 		}
 	}
 	return nil
 }
 
+type marketCoinPrices struct {
+	market     string
+	coinPrices map[string]*models.CoinPrice
+	err        error
+}
+
+// fetchCoinPrices queries the coins of all markets concurrently and returns the results of markets which
+// have coins to query, each market limits the time of its query itself.
+func (cpl *CoinPriceListen) fetchCoinPrices(marketCoins map[string][]string) map[string]*marketCoinPrices {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	results := make(chan *marketCoinPrices, len(cpl.priceMarket))
+	wg := sync.WaitGroup{}
+	for market, query := range cpl.priceMarket {
+		coins, ok := marketCoins[market]
+		if !ok {
+			logs.Error("there is no coins of market: %s", market)
+			continue
+		}
+		wg.Add(1)
+		go func(market string, query PriceMarket, coins []string) {
+			defer wg.Done()
+			coinPrices, err := query.GetCoinPrice(ctx, coins)
+			results <- &marketCoinPrices{market: market, coinPrices: coinPrices, err: err}
+		}(market, query, coins)
+	}
+	wg.Wait()
+	close(results)
+	marketResults := make(map[string]*marketCoinPrices)
+	for result := range results {
+		marketResults[result.market] = result
+	}
+	return marketResults
+}
+
 func (cpl *CoinPriceListen) GetPriceMarket() string {
 	priceMarkets := make([]string, 0)
 	for _, priceMarket := range cpl.priceMarket {
@@ -0,0 +1,69 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package coinpricelisten
+
+import (
+	"github.com/shopspring/decimal"
+	"price_notify/models"
+	"strings"
+)
+
+// CROSS_RATE_SEPARATOR joins the coins of a cross rate path, the name of a price market such as
+// "XXXBTC*BTCUSDT" is priced as the product of XXXBTC and BTCUSDT from the same market.
+var CROSS_RATE_SEPARATOR = "*"
+
+// crossRateCoins returns the coins to query for the name of a price market
+func crossRateCoins(name string) []string {
+	coins := strings.Split(name, CROSS_RATE_SEPARATOR)
+	for i := range coins {
+		coins[i] = strings.TrimSpace(coins[i])
+	}
+	return coins
+}
+
+// crossRatePrice computes the price of a price market name from the prices of coins, it is not ok if the
+// price of any coin in the path is missed. The volume of the first coin is converted to the last quote
+// currency by the prices of the following coins, and the time is the earliest one.
+func crossRatePrice(name string, coinPrices map[string]*models.CoinPrice) (*models.CoinPrice, bool) {
+	price := &models.CoinPrice{Price: decimal.NewFromInt(1)}
+	for i, coin := range crossRateCoins(name) {
+		coinPrice, ok := coinPrices[coin]
+		if !ok || coinPrice == nil {
+			return nil, false
+		}
+		price.Price = price.Price.Mul(coinPrice.Price)
+		if i == 0 {
+			price.Volume = coinPrice.Volume
+		} else {
+			price.Volume = price.Volume.Mul(coinPrice.Price)
+		}
+		if price.Time == 0 || (coinPrice.Time != 0 && coinPrice.Time < price.Time) {
+			price.Time = coinPrice.Time
+		}
+	}
+	return price, true
+}
+
+func containsCoin(coins []string, coin string) bool {
+	for _, item := range coins {
+		if item == coin {
+			return true
+		}
+	}
+	return false
+}
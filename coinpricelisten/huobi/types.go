@@ -0,0 +1,36 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package huobi
+
+import "github.com/shopspring/decimal"
+
+// Ticker struct
+type Ticker struct {
+	Symbol  string          `json:"symbol"`
+	Open    decimal.Decimal `json:"open"`
+	High    decimal.Decimal `json:"high"`
+	Low     decimal.Decimal `json:"low"`
+	Close   decimal.Decimal `json:"close"`
+	Amount  decimal.Decimal `json:"amount"`
+	Vol     decimal.Decimal `json:"vol"`
+	Count   int64           `json:"count"`
+	Bid     decimal.Decimal `json:"bid"`
+	BidSize decimal.Decimal `json:"bidSize"`
+	Ask     decimal.Decimal `json:"ask"`
+	AskSize decimal.Decimal `json:"askSize"`
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package huobi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/astaxie/beego/logs"
+	"io/ioutil"
+	"net/http"
+	"price_notify/basedef"
+	"price_notify/conf"
+	"price_notify/models"
+	"strings"
+)
+
+type HuobiSdk struct {
+	client *http.Client
+	nodes  []*conf.Restful
+}
+
+func DefaultHuobiSdk() *HuobiSdk {
+	client := &http.Client{}
+	sdk := &HuobiSdk{
+		client: client,
+		nodes: []*conf.Restful{
+			{
+				Url: "https://api.huobi.pro/",
+			},
+		},
+	}
+	return sdk
+}
+
+func NewHuobiSdk(cfg *conf.CoinPriceListenConfig) *HuobiSdk {
+	client := &http.Client{}
+	sdk := &HuobiSdk{
+		client: client,
+		nodes:  cfg.Nodes,
+	}
+	return sdk
+}
+
+type TickersMedia struct {
+	Status  string    `json:"status"`
+	Ts      int64     `json:"ts"`
+	ErrCode string    `json:"err-code"`
+	ErrMsg  string    `json:"err-msg"`
+	Data    []*Ticker `json:"data"`
+}
+
+func (sdk *HuobiSdk) QuotesLatest(ctx context.Context) (*TickersMedia, error) {
+	for i := 0; i < len(sdk.nodes); i++ {
+		quotes, err := sdk.quotesLatest(ctx, i)
+		if err != nil {
+			logs.Error("Huobi QuotesLatest err: %s", err.Error())
+			continue
+		} else {
+			return quotes, nil
+		}
+	}
+	return nil, fmt.Errorf("Cannot get Huobi QuotesLatest!")
+}
+
+func (sdk *HuobiSdk) quotesLatest(ctx context.Context, node int) (*TickersMedia, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", sdk.nodes[node].Url+"market/tickers", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accepts", "application/json")
+
+	resp, err := sdk.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("response status code: %d", resp.StatusCode)
+	}
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	body := new(TickersMedia)
+	err = json.Unmarshal(respBody, body)
+	if err != nil {
+		return nil, err
+	}
+	if body.Status != "ok" {
+		return nil, fmt.Errorf("response status: %s, code: %s, err: %s", body.Status, body.ErrCode, body.ErrMsg)
+	}
+	return body, nil
+}
+
+func (sdk *HuobiSdk) GetMarketName() string {
+	return basedef.MARKET_HUOBI
+}
+
+// NormalizeSymbol converts a configured coin name such as "BTCUSDT", "BTC/USDT" or "btc-usdt"
+// to the lower case symbol used by Huobi, e.g. "btcusdt".
+func NormalizeSymbol(coin string) string {
+	symbol := strings.ToLower(strings.TrimSpace(coin))
+	return strings.NewReplacer("/", "", "-", "", "_", "", " ", "").Replace(symbol)
+}
+
+func (sdk *HuobiSdk) GetCoinPrice(ctx context.Context, coins []string) (map[string]*models.CoinPrice, error) {
+	quotes, err := sdk.QuotesLatest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	coinSymbol2Ticker := make(map[string]*Ticker, 0)
+	for _, v := range quotes.Data {
+		coinSymbol2Ticker[v.Symbol] = v
+	}
+	coinPrice := make(map[string]*models.CoinPrice, 0)
+	for _, coin := range coins {
+		ticker, ok := coinSymbol2Ticker[NormalizeSymbol(coin)]
+		if !ok {
+			logs.Warn("There is no coin price %s in Huobi!", coin)
+			continue
+		}
+		coinPrice[coin] = &models.CoinPrice{Price: ticker.Close, Volume: ticker.Vol, Time: quotes.Ts / 1000}
+	}
+	return coinPrice, nil
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package kraken
+
+// AssetPair struct
+type AssetPair struct {
+	Altname string `json:"altname"`
+	Wsname  string `json:"wsname"`
+	Base    string `json:"base"`
+	Quote   string `json:"quote"`
+}
+
+// Ticker struct, every field is an array of strings
+// c: last trade closed [price, lot volume]
+// v: volume [today, last 24 hours]
+type Ticker struct {
+	Ask       []string `json:"a"`
+	Bid       []string `json:"b"`
+	Close     []string `json:"c"`
+	Volume    []string `json:"v"`
+	VwapPrice []string `json:"p"`
+	Trades    []int64  `json:"t"`
+	Low       []string `json:"l"`
+	High      []string `json:"h"`
+	Open      string   `json:"o"`
+}
@@ -0,0 +1,232 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package kraken
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/astaxie/beego/logs"
+	"github.com/shopspring/decimal"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"price_notify/basedef"
+	"price_notify/conf"
+	"price_notify/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+type KrakenSdk struct {
+	client *http.Client
+	nodes  []*conf.Restful
+	// readable pair name, including the pair name itself, altname and wsname, to kraken pair name
+	pairs     map[string]string
+	pairsLock sync.Mutex
+}
+
+func DefaultKrakenSdk() *KrakenSdk {
+	client := &http.Client{}
+	sdk := &KrakenSdk{
+		client: client,
+		nodes: []*conf.Restful{
+			{
+				Url: "https://api.kraken.com/",
+			},
+		},
+	}
+	return sdk
+}
+
+func NewKrakenSdk(cfg *conf.CoinPriceListenConfig) *KrakenSdk {
+	client := &http.Client{}
+	sdk := &KrakenSdk{
+		client: client,
+		nodes:  cfg.Nodes,
+	}
+	return sdk
+}
+
+// Media is the envelope of kraken api, a non empty error means failure
+type Media struct {
+	Error  []string        `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+func (sdk *KrakenSdk) AssetPairs(ctx context.Context) (map[string]*AssetPair, error) {
+	for i := 0; i < len(sdk.nodes); i++ {
+		pairs, err := sdk.assetPairs(ctx, i)
+		if err != nil {
+			logs.Error("Kraken AssetPairs err: %s", err.Error())
+			continue
+		} else {
+			return pairs, nil
+		}
+	}
+	return nil, fmt.Errorf("Cannot get Kraken AssetPairs!")
+}
+
+func (sdk *KrakenSdk) assetPairs(ctx context.Context, node int) (map[string]*AssetPair, error) {
+	pairs := make(map[string]*AssetPair)
+	err := sdk.request(ctx, node, "0/public/AssetPairs", nil, &pairs)
+	if err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+func (sdk *KrakenSdk) QuotesLatest(ctx context.Context, pairs string) (map[string]*Ticker, error) {
+	for i := 0; i < len(sdk.nodes); i++ {
+		quotes, err := sdk.quotesLatest(ctx, pairs, i)
+		if err != nil {
+			logs.Error("Kraken QuotesLatest err: %s", err.Error())
+			continue
+		} else {
+			return quotes, nil
+		}
+	}
+	return nil, fmt.Errorf("Cannot get Kraken QuotesLatest!")
+}
+
+func (sdk *KrakenSdk) quotesLatest(ctx context.Context, pairs string, node int) (map[string]*Ticker, error) {
+	q := url.Values{}
+	q.Add("pair", pairs)
+	tickers := make(map[string]*Ticker)
+	err := sdk.request(ctx, node, "0/public/Ticker", q, &tickers)
+	if err != nil {
+		return nil, err
+	}
+	return tickers, nil
+}
+
+func (sdk *KrakenSdk) request(ctx context.Context, node int, path string, q url.Values, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", sdk.nodes[node].Url+path, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accepts", "application/json")
+	if q != nil {
+		req.URL.RawQuery = q.Encode()
+	}
+
+	resp, err := sdk.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("response status code: %d", resp.StatusCode)
+	}
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	var body Media
+	err = json.Unmarshal(respBody, &body)
+	if err != nil {
+		return err
+	}
+	if len(body.Error) > 0 {
+		return fmt.Errorf("response error: %s", strings.Join(body.Error, ","))
+	}
+	return json.Unmarshal(body.Result, result)
+}
+
+// getPairs reads the asset pairs once and caches the readable name to pair name mapping,
+// the asset pairs are read again only if the last reading failed.
+func (sdk *KrakenSdk) getPairs(ctx context.Context) (map[string]string, error) {
+	sdk.pairsLock.Lock()
+	defer sdk.pairsLock.Unlock()
+	if sdk.pairs != nil {
+		return sdk.pairs, nil
+	}
+	assetPairs, err := sdk.AssetPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make(map[string]string)
+	for name, assetPair := range assetPairs {
+		pairs[strings.ToUpper(name)] = name
+		if assetPair.Altname != "" {
+			pairs[strings.ToUpper(assetPair.Altname)] = name
+		}
+		if assetPair.Wsname != "" {
+			pairs[strings.ToUpper(assetPair.Wsname)] = name
+		}
+	}
+	sdk.pairs = pairs
+	return pairs, nil
+}
+
+func (sdk *KrakenSdk) GetMarketName() string {
+	return basedef.MARKET_KRAKEN
+}
+
+func (sdk *KrakenSdk) GetCoinPrice(ctx context.Context, coins []string) (map[string]*models.CoinPrice, error) {
+	pairs, err := sdk.getPairs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	//
+	pair2Coins := make(map[string][]string, 0)
+	for _, coin := range coins {
+		pair, ok := pairs[strings.ToUpper(strings.TrimSpace(coin))]
+		if !ok {
+			logs.Warn("There is no coin %s in Kraken!", coin)
+			continue
+		}
+		pair2Coins[pair] = append(pair2Coins[pair], coin)
+	}
+	coinPrice := make(map[string]*models.CoinPrice, 0)
+	if len(pair2Coins) == 0 {
+		return coinPrice, nil
+	}
+	requestPairs := make([]string, 0, len(pair2Coins))
+	for pair := range pair2Coins {
+		requestPairs = append(requestPairs, pair)
+	}
+	//
+	quotes, err := sdk.QuotesLatest(ctx, strings.Join(requestPairs, ","))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	for pair, v := range quotes {
+		if len(v.Close) == 0 {
+			logs.Warn("There is no price for pair %s in Kraken!", pair)
+			continue
+		}
+		price, err := decimal.NewFromString(v.Close[0])
+		if err != nil {
+			logs.Warn("Invalid price %s for pair %s in Kraken!", v.Close[0], pair)
+			continue
+		}
+		// volume of the last 24 hours is in base currency
+		volume := decimal.Zero
+		if len(v.Volume) > 1 {
+			volume, err = decimal.NewFromString(v.Volume[1])
+			if err != nil {
+				volume = decimal.Zero
+			}
+		}
+		for _, coin := range pair2Coins[pair] {
+			coinPrice[coin] = &models.CoinPrice{Price: price, Volume: volume.Mul(price), Time: now}
+		}
+	}
+	return coinPrice, nil
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package coinpricelisten
+
+import (
+	"fmt"
+	"price_notify/conf"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PriceMarketSchema describes which fields of CoinPriceListenConfig a price market accepts
+type PriceMarketSchema struct {
+	// the market needs at least one node
+	RequireNodes bool
+	// every node of the market needs a key
+	RequireKey bool
+	// the market supports CacheFile and CacheRefreshSlot
+	Cache bool
+	// the values of Stream which the market supports, Stream must be empty if there is none
+	Streams []string
+}
+
+// PriceMarketFactory creates the price market of a market name
+type PriceMarketFactory struct {
+	Schema *PriceMarketSchema
+	// Validate checks the config further than the schema, it is optional
+	Validate func(cfg *conf.CoinPriceListenConfig) error
+	New      func(cfg *conf.CoinPriceListenConfig) (PriceMarket, error)
+}
+
+var (
+	priceMarketFactories     = make(map[string]*PriceMarketFactory)
+	priceMarketFactoriesLock sync.RWMutex
+)
+
+// RegisterPriceMarket makes a price market available by the market name, it panics if the market
+// name is registered twice. Packages outside coinpricelisten can call it from their init function.
+func RegisterPriceMarket(marketName string, factory *PriceMarketFactory) {
+	priceMarketFactoriesLock.Lock()
+	defer priceMarketFactoriesLock.Unlock()
+	if factory == nil || factory.New == nil {
+		panic("price market factory of " + marketName + " is nil")
+	}
+	if _, ok := priceMarketFactories[marketName]; ok {
+		panic("price market " + marketName + " is registered twice")
+	}
+	priceMarketFactories[marketName] = factory
+}
+
+// UnregisterPriceMarket removes the price market of the market name, so tests can register it again
+func UnregisterPriceMarket(marketName string) {
+	priceMarketFactoriesLock.Lock()
+	defer priceMarketFactoriesLock.Unlock()
+	delete(priceMarketFactories, marketName)
+}
+
+// PriceMarketNames returns the sorted names of registered price markets
+func PriceMarketNames() []string {
+	priceMarketFactoriesLock.RLock()
+	defer priceMarketFactoriesLock.RUnlock()
+	names := make([]string, 0, len(priceMarketFactories))
+	for name := range priceMarketFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func getPriceMarketFactory(marketName string) (*PriceMarketFactory, error) {
+	priceMarketFactoriesLock.RLock()
+	factory, ok := priceMarketFactories[marketName]
+	priceMarketFactoriesLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown price market: %s, known price markets: %s",
+			marketName, strings.Join(PriceMarketNames(), ", "))
+	}
+	return factory, nil
+}
+
+// ValidatePriceMarket checks the config against the schema of its price market
+func ValidatePriceMarket(cfg *conf.CoinPriceListenConfig) error {
+	if cfg == nil {
+		return fmt.Errorf("price market config is nil")
+	}
+	factory, err := getPriceMarketFactory(cfg.MarketName)
+	if err != nil {
+		return err
+	}
+	schema := factory.Schema
+	if schema != nil {
+		if schema.RequireNodes && len(cfg.Nodes) == 0 {
+			return fmt.Errorf("price market %s: there is no node", cfg.MarketName)
+		}
+		for i, node := range cfg.Nodes {
+			if node == nil || node.Url == "" {
+				return fmt.Errorf("price market %s: url of node %d is empty", cfg.MarketName, i)
+			}
+			if schema.RequireKey && node.Key == "" {
+				return fmt.Errorf("price market %s: key of node %d is empty", cfg.MarketName, i)
+			}
+		}
+		if !schema.Cache && (cfg.CacheFile != "" || cfg.CacheRefreshSlot != 0) {
+			return fmt.Errorf("price market %s: CacheFile and CacheRefreshSlot are not supported", cfg.MarketName)
+		}
+		if cfg.Timeout < 0 {
+			return fmt.Errorf("price market %s: Timeout is negative", cfg.MarketName)
+		}
+		if len(cfg.Coins) > 0 && len(schema.Streams) == 0 {
+			return fmt.Errorf("price market %s: Coins is not supported", cfg.MarketName)
+		}
+		if cfg.Stream != "" && !containsCoin(schema.Streams, cfg.Stream) {
+			if len(schema.Streams) == 0 {
+				return fmt.Errorf("price market %s: Stream is not supported", cfg.MarketName)
+			}
+			return fmt.Errorf("price market %s: unknown Stream %s, supported streams: %s",
+				cfg.MarketName, cfg.Stream, strings.Join(schema.Streams, ", "))
+		}
+	}
+	if factory.Validate != nil {
+		err = factory.Validate(cfg)
+		if err != nil {
+			return fmt.Errorf("price market %s: %v", cfg.MarketName, err)
+		}
+	}
+	return nil
+}
+
+// NewPriceMarket validates the config and creates the price market registered by its market name,
+// every query of the price market is limited by the Timeout of config.
+func NewPriceMarket(cfg *conf.CoinPriceListenConfig) (PriceMarket, error) {
+	err := ValidatePriceMarket(cfg)
+	if err != nil {
+		return nil, err
+	}
+	factory, err := getPriceMarketFactory(cfg.MarketName)
+	if err != nil {
+		return nil, err
+	}
+	priceMarket, err := factory.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return limitPriceMarket(priceMarket, cfg.Timeout), nil
+}
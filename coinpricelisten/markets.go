@@ -0,0 +1,85 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package coinpricelisten
+
+import (
+	"fmt"
+	"price_notify/basedef"
+	"price_notify/coinpricelisten/binance"
+	"price_notify/coinpricelisten/coinbase"
+	"price_notify/coinpricelisten/coingecko"
+	"price_notify/coinpricelisten/coinmarketcap"
+	"price_notify/coinpricelisten/huobi"
+	"price_notify/coinpricelisten/kraken"
+	"price_notify/coinpricelisten/okx"
+	"price_notify/conf"
+)
+
+func init() {
+	RegisterPriceMarket(basedef.MARKET_COINMARKETCAP, &PriceMarketFactory{
+		Schema: &PriceMarketSchema{RequireNodes: true, RequireKey: true, Cache: true},
+		New: func(cfg *conf.CoinPriceListenConfig) (PriceMarket, error) {
+			return coinmarketcap.NewCoinMarketCapSdk(cfg), nil
+		},
+	})
+	RegisterPriceMarket(basedef.MARKET_BINANCE, &PriceMarketFactory{
+		Schema: &PriceMarketSchema{RequireNodes: true, Streams: []string{binance.STREAM_MINITICKER, binance.STREAM_BOOKTICKER}},
+		Validate: func(cfg *conf.CoinPriceListenConfig) error {
+			if cfg.Stream == "" && cfg.StaleSlot != 0 {
+				return fmt.Errorf("StaleSlot is only supported by Stream")
+			}
+			return nil
+		},
+		New: func(cfg *conf.CoinPriceListenConfig) (PriceMarket, error) {
+			if cfg.Stream != "" {
+				return binance.NewBinanceStreamSdk(cfg), nil
+			}
+			return binance.NewBinanceSdk(cfg), nil
+		},
+	})
+	RegisterPriceMarket(basedef.MARKET_HUOBI, &PriceMarketFactory{
+		Schema: &PriceMarketSchema{RequireNodes: true},
+		New: func(cfg *conf.CoinPriceListenConfig) (PriceMarket, error) {
+			return huobi.NewHuobiSdk(cfg), nil
+		},
+	})
+	RegisterPriceMarket(basedef.MARKET_OKX, &PriceMarketFactory{
+		Schema: &PriceMarketSchema{RequireNodes: true},
+		New: func(cfg *conf.CoinPriceListenConfig) (PriceMarket, error) {
+			return okx.NewOkxSdk(cfg), nil
+		},
+	})
+	RegisterPriceMarket(basedef.MARKET_COINBASE, &PriceMarketFactory{
+		Schema: &PriceMarketSchema{RequireNodes: true},
+		New: func(cfg *conf.CoinPriceListenConfig) (PriceMarket, error) {
+			return coinbase.NewCoinbaseSdk(cfg), nil
+		},
+	})
+	RegisterPriceMarket(basedef.MARKET_KRAKEN, &PriceMarketFactory{
+		Schema: &PriceMarketSchema{RequireNodes: true},
+		New: func(cfg *conf.CoinPriceListenConfig) (PriceMarket, error) {
+			return kraken.NewKrakenSdk(cfg), nil
+		},
+	})
+	RegisterPriceMarket(basedef.MARKET_COINGECKO, &PriceMarketFactory{
+		Schema: &PriceMarketSchema{RequireNodes: true, Cache: true},
+		New: func(cfg *conf.CoinPriceListenConfig) (PriceMarket, error) {
+			return coingecko.NewCoinGeckoSdk(cfg), nil
+		},
+	})
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package okx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/astaxie/beego/logs"
+	"github.com/shopspring/decimal"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"price_notify/basedef"
+	"price_notify/conf"
+	"price_notify/models"
+	"strconv"
+	"strings"
+)
+
+type OkxSdk struct {
+	client *http.Client
+	nodes  []*conf.Restful
+}
+
+func DefaultOkxSdk() *OkxSdk {
+	client := &http.Client{}
+	sdk := &OkxSdk{
+		client: client,
+		nodes: []*conf.Restful{
+			{
+				Url: "https://www.okx.com/",
+			},
+		},
+	}
+	return sdk
+}
+
+func NewOkxSdk(cfg *conf.CoinPriceListenConfig) *OkxSdk {
+	client := &http.Client{}
+	sdk := &OkxSdk{
+		client: client,
+		nodes:  cfg.Nodes,
+	}
+	return sdk
+}
+
+// TickersMedia is the envelope of okx v5 api, code "0" means success
+type TickersMedia struct {
+	Code string    `json:"code"`
+	Msg  string    `json:"msg"`
+	Data []*Ticker `json:"data"`
+}
+
+func (sdk *OkxSdk) QuotesLatest(ctx context.Context) ([]*Ticker, error) {
+	for i := 0; i < len(sdk.nodes); i++ {
+		quotes, err := sdk.quotesLatest(ctx, i)
+		if err != nil {
+			logs.Error("Okx QuotesLatest err: %s", err.Error())
+			continue
+		} else {
+			return quotes, nil
+		}
+	}
+	return nil, fmt.Errorf("Cannot get Okx QuotesLatest!")
+}
+
+func (sdk *OkxSdk) quotesLatest(ctx context.Context, node int) ([]*Ticker, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", sdk.nodes[node].Url+"api/v5/market/tickers", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	q := url.Values{}
+	q.Add("instType", "SPOT")
+
+	req.Header.Set("Accepts", "application/json")
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := sdk.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("response status code: %d", resp.StatusCode)
+	}
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	var body TickersMedia
+	err = json.Unmarshal(respBody, &body)
+	if err != nil {
+		return nil, err
+	}
+	if body.Code != "0" {
+		return nil, fmt.Errorf("response code: %s, msg: %s", body.Code, body.Msg)
+	}
+	return body.Data, nil
+}
+
+func (sdk *OkxSdk) GetMarketName() string {
+	return basedef.MARKET_OKX
+}
+
+// NormalizeInstId converts a configured coin name such as "btc/usdt" or "BTC_USDT"
+// to the instrument id used by okx, e.g. "BTC-USDT".
+func NormalizeInstId(coin string) string {
+	instId := strings.ToUpper(strings.TrimSpace(coin))
+	return strings.NewReplacer("/", "-", "_", "-").Replace(instId)
+}
+
+func (sdk *OkxSdk) GetCoinPrice(ctx context.Context, coins []string) (map[string]*models.CoinPrice, error) {
+	quotes, err := sdk.QuotesLatest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	instId2Price := make(map[string]*models.CoinPrice, 0)
+	for _, v := range quotes {
+		price, err := decimal.NewFromString(v.Last)
+		if err != nil {
+			continue
+		}
+		// volCcy24h of spot is the volume in quote currency
+		volume, err := decimal.NewFromString(v.VolCcy24h)
+		if err != nil {
+			volume = decimal.Zero
+		}
+		ts, _ := strconv.ParseInt(v.Ts, 10, 64)
+		instId2Price[v.InstId] = &models.CoinPrice{Price: price, Volume: volume, Time: ts / 1000}
+	}
+	coinPrice := make(map[string]*models.CoinPrice, 0)
+	for _, coin := range coins {
+		price, ok := instId2Price[NormalizeInstId(coin)]
+		if !ok {
+			logs.Warn("There is no coin price %s in Okx!", coin)
+			continue
+		}
+		coinPrice[coin] = price
+	}
+	return coinPrice, nil
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+package coinpricelisten
+
+import (
+	"github.com/astaxie/beego/logs"
+	"github.com/shopspring/decimal"
+	"price_notify/basedef"
+	"price_notify/conf"
+	"price_notify/models"
+	"sort"
+)
+
+var (
+	HISTORY_BUCKET_RAW    = int64(0)
+	HISTORY_BUCKET_MINUTE = int64(60)
+	HISTORY_BUCKET_HOUR   = int64(3600)
+	HISTORY_BUCKET_DAY    = int64(86400)
+	// the samples of a bucket are rolled up into the next bucket
+	HISTORY_BUCKETS = []int64{HISTORY_BUCKET_RAW, HISTORY_BUCKET_MINUTE, HISTORY_BUCKET_HOUR, HISTORY_BUCKET_DAY}
+)
+
+var (
+	DEFAULT_ROLLUP_SLOT      = int64(600)
+	DEFAULT_RAW_RETENTION    = int64(86400)
+	DEFAULT_MINUTE_RETENTION = int64(86400 * 7)
+	DEFAULT_HOUR_RETENTION   = int64(86400 * 90)
+	DEFAULT_DAY_RETENTION    = int64(-1)
+)
+
+func newHistoryConfig(cfg *conf.PriceHistoryConfig) *conf.PriceHistoryConfig {
+	historyCfg := &conf.PriceHistoryConfig{}
+	if cfg != nil {
+		*historyCfg = *cfg
+	}
+	if historyCfg.RollupSlot <= 0 {
+		historyCfg.RollupSlot = DEFAULT_ROLLUP_SLOT
+	}
+	historyCfg.RawRetention = historyRetention(historyCfg.RawRetention, DEFAULT_RAW_RETENTION, HISTORY_BUCKET_MINUTE)
+	historyCfg.MinuteRetention = historyRetention(historyCfg.MinuteRetention, DEFAULT_MINUTE_RETENTION, HISTORY_BUCKET_HOUR)
+	historyCfg.HourRetention = historyRetention(historyCfg.HourRetention, DEFAULT_HOUR_RETENTION, HISTORY_BUCKET_DAY)
+	historyCfg.DayRetention = historyRetention(historyCfg.DayRetention, DEFAULT_DAY_RETENTION, 0)
+	return historyCfg
+}
+
+// historyRetention returns the retention of a bucket, it is at least the next bucket so that the samples are
+// rolled up before they are deleted.
+func historyRetention(retention int64, defaultRetention int64, nextBucket int64) int64 {
+	if retention == 0 {
+		retention = defaultRetention
+	}
+	if retention > 0 && retention < nextBucket {
+		retention = nextBucket
+	}
+	return retention
+}
+
+func (cpl *CoinPriceListen) historyRetention(bucket int64) int64 {
+	switch bucket {
+	case HISTORY_BUCKET_RAW:
+		return cpl.historyCfg.RawRetention
+	case HISTORY_BUCKET_MINUTE:
+		return cpl.historyCfg.MinuteRetention
+	case HISTORY_BUCKET_HOUR:
+		return cpl.historyCfg.HourRetention
+	default:
+		return cpl.historyCfg.DayRetention
+	}
+}
+
+type historySource struct {
+	tokenName  string
+	marketName string
+}
+
+// newPriceHistories returns the samples of the prices updated at now, the aggregated price of token has no market
+// name. The samples are stamped with now, so the rollup of a bucket does not miss the markets whose time lags, and
+// a price is skipped if its time does not advance since the last sample of it.
+func (cpl *CoinPriceListen) newPriceHistories(tokenBasics []*models.TokenBasic, now int64) []*models.PriceHistory {
+	histories := make([]*models.PriceHistory, 0)
+	addHistory := func(tokenName string, marketName string, price int64, priceTime int64) {
+		source := historySource{tokenName: tokenName, marketName: marketName}
+		if priceTime <= cpl.historyTimes[source] {
+			return
+		}
+		cpl.historyTimes[source] = priceTime
+		histories = append(histories, &models.PriceHistory{
+			TokenBasicName: tokenName,
+			MarketName:     marketName,
+			Bucket:         HISTORY_BUCKET_RAW,
+			Time:           now,
+			Price:          price,
+			Count:          1,
+		})
+	}
+	for _, tokenBasic := range tokenBasics {
+		if tokenBasic.PriceInd != basedef.PRICE_IND_FRESH {
+			continue
+		}
+		addHistory(tokenBasic.Name, "", tokenBasic.Price, tokenBasic.Time)
+		for _, tokenPrice := range tokenBasic.PriceMarkets {
+			if tokenPrice.PriceInd != basedef.PRICE_IND_FRESH {
+				continue
+			}
+			addHistory(tokenBasic.Name, tokenPrice.MarketName, tokenPrice.Price, tokenPrice.Time)
+		}
+	}
+	return histories
+}
+
+// RollupPriceHistory rolls up the samples of every bucket into the next bucket, only the buckets which are
+// complete at now are rolled up. The histories older than the retention of their bucket are deleted then.
+func (cpl *CoinPriceListen) RollupPriceHistory(now int64) error {
+	for i := 1; i < len(HISTORY_BUCKETS); i++ {
+		err := cpl.rollupPriceHistory(HISTORY_BUCKETS[i-1], HISTORY_BUCKETS[i], now)
+		if err != nil {
+			return err
+		}
+	}
+	for _, bucket := range HISTORY_BUCKETS {
+		retention := cpl.historyRetention(bucket)
+		if retention < 0 {
+			continue
+		}
+		err := cpl.db.DeletePriceHistories(bucket, now-retention)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type historyKey struct {
+	tokenName  string
+	marketName string
+	time       int64
+}
+
+func (cpl *CoinPriceListen) rollupPriceHistory(source int64, bucket int64, now int64) error {
+	start, err := cpl.db.GetLastPriceHistoryTime(bucket)
+	if err != nil {
+		return err
+	}
+	if start > 0 {
+		start += bucket
+	}
+	end := now / bucket * bucket
+	if start >= end {
+		return nil
+	}
+	histories, err := cpl.db.GetPriceHistories(source, start, end)
+	if err != nil {
+		return err
+	}
+	totalPrices := make(map[historyKey]decimal.Decimal)
+	counts := make(map[historyKey]int64)
+	for _, history := range histories {
+		key := historyKey{tokenName: history.TokenBasicName, marketName: history.MarketName, time: history.Time / bucket * bucket}
+		price := decimal.NewFromInt(history.Price).Mul(decimal.NewFromInt(history.Count))
+		totalPrices[key] = totalPrices[key].Add(price)
+		counts[key] += history.Count
+	}
+	rollups := make([]*models.PriceHistory, 0, len(counts))
+	for key, count := range counts {
+		// the average price of samples is rounded half to even
+		price, err := basedef.PriceToInt64(totalPrices[key].Div(decimal.NewFromInt(count)), 0)
+		if err != nil {
+			logs.Error("roll up price history of token %s in market %s at %d err: %v", key.tokenName, key.marketName, key.time, err)
+			continue
+		}
+		rollups = append(rollups, &models.PriceHistory{
+			TokenBasicName: key.tokenName,
+			MarketName:     key.marketName,
+			Bucket:         bucket,
+			Time:           key.time,
+			Price:          price,
+			Count:          count,
+		})
+	}
+	sort.Slice(rollups, func(i, j int) bool {
+		if rollups[i].Time != rollups[j].Time {
+			return rollups[i].Time < rollups[j].Time
+		}
+		if rollups[i].TokenBasicName != rollups[j].TokenBasicName {
+			return rollups[i].TokenBasicName < rollups[j].TokenBasicName
+		}
+		return rollups[i].MarketName < rollups[j].MarketName
+	})
+	logs.Info("roll up %d price histories of bucket %d into %d histories of bucket %d", len(histories), source, len(rollups), bucket)
+	return cpl.db.AddPriceHistories(rollups)
+}
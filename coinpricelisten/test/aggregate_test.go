@@ -0,0 +1,177 @@
+package test
+
+import (
+	"price_notify/basedef"
+	"price_notify/coinpricelisten"
+	"price_notify/conf"
+	"price_notify/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newAggregateMarkets returns 4 markets quoting BTC, one of them is broken
+func newAggregateMarkets() []coinpricelisten.PriceMarket {
+	return []coinpricelisten.PriceMarket{
+		&mockPriceMarket{name: "m1", prices: map[string]float64{"BTC": 100}},
+		&mockPriceMarket{name: "m2", prices: map[string]float64{"BTC": 101}},
+		&mockPriceMarket{name: "m3", prices: map[string]float64{"BTC": 102}},
+		&mockPriceMarket{name: "m4", prices: map[string]float64{"BTC": 1000}},
+	}
+}
+
+func TestAggregateVwap(t *testing.T) {
+	markets := []coinpricelisten.PriceMarket{
+		&mockPriceMarket{name: "m1", prices: map[string]float64{"BTC": 100}, volumes: map[string]float64{"BTC": 3000000}},
+		&mockPriceMarket{name: "m2", prices: map[string]float64{"BTC": 104}, volumes: map[string]float64{"BTC": 1000000}},
+		&mockPriceMarket{name: "m3", prices: map[string]float64{"BTC": 102}, volumes: map[string]float64{"BTC": 0}},
+		&mockPriceMarket{name: "m4", prices: map[string]float64{"BTC": 1000}, volumes: map[string]float64{"BTC": 10}},
+	}
+	dao := &mockCoinPriceDao{tokens: []*models.TokenBasic{newAggregateToken("BTC", basedef.AGGREGATE_VWAP)}}
+	coinpricelisten.NewCoinPriceListen(1, markets, nil, nil, dao)
+	token := dao.token("BTC")
+	// (100 * 3000000 + 104 * 1000000 + 1000 * 10) / 4000010
+	if token.Price != 10100224749 {
+		t.Errorf("expected vwap price 101.00224749, got %d", token.Price)
+	}
+	if marketOf(token, "m3").ExcludeReason == "" || marketOf(token, "m1").Volume != 3000000 {
+		t.Errorf("market without volume should be excluded")
+	}
+}
+
+func newAggregateToken(name string, aggregation string) *models.TokenBasic {
+	token := &models.TokenBasic{Name: name, Aggregation: aggregation}
+	for _, market := range []string{"m1", "m2", "m3", "m4"} {
+		token.PriceMarkets = append(token.PriceMarkets, &models.PriceMarket{TokenBasicName: name, MarketName: market, Name: "BTC"})
+	}
+	return token
+}
+
+func marketOf(token *models.TokenBasic, market string) *models.PriceMarket {
+	for _, tokenPrice := range token.PriceMarkets {
+		if tokenPrice.MarketName == market {
+			return tokenPrice
+		}
+	}
+	return nil
+}
+
+func TestAggregateStrategies(t *testing.T) {
+	expected := map[string]float64{
+		basedef.AGGREGATE_MEAN:         325.75,
+		basedef.AGGREGATE_MEDIAN:       101.5,
+		basedef.AGGREGATE_TRIMMED_MEAN: 101.5,
+		basedef.AGGREGATE_MAD:          101,
+	}
+	for strategy, price := range expected {
+		dao := &mockCoinPriceDao{tokens: []*models.TokenBasic{newAggregateToken("BTC", "")}}
+		coinpricelisten.NewCoinPriceListen(1, newAggregateMarkets(), &conf.CoinPriceAggregateConfig{Strategy: strategy, TrimPercent: 25}, nil, dao)
+		token := dao.token("BTC")
+		if token.PriceInd != 1 || token.Price != int64(price*float64(basedef.PRICE_PRECISION)) {
+			t.Errorf("%s: expected price %v, got %d", strategy, price, token.Price)
+		}
+	}
+}
+
+func TestAggregateExcludeReason(t *testing.T) {
+	dao := &mockCoinPriceDao{
+		tokens: []*models.TokenBasic{
+			newAggregateToken("BTC", ""),
+			newAggregateToken("WBTC", basedef.AGGREGATE_TRIMMED_MEAN),
+		},
+	}
+	coinpricelisten.NewCoinPriceListen(1, newAggregateMarkets(), &conf.CoinPriceAggregateConfig{Strategy: basedef.AGGREGATE_MAD, TrimPercent: 25}, nil, dao)
+	btc := dao.token("BTC")
+	if reason := marketOf(btc, "m4").ExcludeReason; !strings.Contains(reason, "MAD") {
+		t.Errorf("broken market should be excluded by MAD, reason: %s", reason)
+	}
+	for _, market := range []string{"m1", "m2", "m3"} {
+		if reason := marketOf(btc, market).ExcludeReason; reason != "" {
+			t.Errorf("market %s should not be excluded, reason: %s", market, reason)
+		}
+	}
+	wbtc := dao.token("WBTC")
+	if !strings.Contains(marketOf(wbtc, "m1").ExcludeReason, "lowest") || !strings.Contains(marketOf(wbtc, "m4").ExcludeReason, "highest") {
+		t.Errorf("token aggregation should trim the lowest and highest prices")
+	}
+	if wbtc.Price != 10150000000 {
+		t.Errorf("expected trimmed mean 101.5, got %d", wbtc.Price)
+	}
+}
+
+func TestAggregateMadWithoutDeviation(t *testing.T) {
+	// most of the prices are the same, MAD is 0
+	markets := []coinpricelisten.PriceMarket{
+		&mockPriceMarket{name: "m1", prices: map[string]float64{"BTC": 100}},
+		&mockPriceMarket{name: "m2", prices: map[string]float64{"BTC": 100}},
+		&mockPriceMarket{name: "m3", prices: map[string]float64{"BTC": 100}},
+		&mockPriceMarket{name: "m4", prices: map[string]float64{"BTC": 100.01}},
+	}
+	dao := &mockCoinPriceDao{tokens: []*models.TokenBasic{newAggregateToken("BTC", basedef.AGGREGATE_MAD)}}
+	coinpricelisten.NewCoinPriceListen(1, markets, nil, nil, dao)
+	btc := dao.token("BTC")
+	if reason := marketOf(btc, "m4").ExcludeReason; reason != "" {
+		t.Errorf("price differing by a tick should not be excluded, reason: %s", reason)
+	}
+	if btc.Price != 10000250000 {
+		t.Errorf("expected price 100.0025, got %d", btc.Price)
+	}
+}
+
+func TestAggregateWeightAndPriority(t *testing.T) {
+	weighted := newAggregateToken("BTC", basedef.AGGREGATE_WEIGHTED)
+	marketOf(weighted, "m1").Weight = 3
+	marketOf(weighted, "m4").Weight = 0
+	primary := newAggregateToken("WBTC", basedef.AGGREGATE_PRIMARY)
+	marketOf(primary, "m3").Priority = 10
+	marketOf(primary, "m2").Priority = 5
+	fallback := newAggregateToken("RENBTC", basedef.AGGREGATE_PRIMARY)
+	marketOf(fallback, "m3").Priority = 10
+	marketOf(fallback, "m3").Name = "NOTEXIST"
+	marketOf(fallback, "m2").Priority = 5
+	dao := &mockCoinPriceDao{tokens: []*models.TokenBasic{weighted, primary, fallback}}
+	coinpricelisten.NewCoinPriceListen(1, newAggregateMarkets(), nil, nil, dao)
+	// (100 * 3 + 101 + 102 + 1000) / 6
+	if weighted.Price != 25050000000 {
+		t.Errorf("expected weighted price 250.5, got %d", weighted.Price)
+	}
+	if primary.Price != 10200000000 || marketOf(primary, "m2").ExcludeReason == "" {
+		t.Errorf("expected the price 102 of primary market, got %d", primary.Price)
+	}
+	if fallback.Price != 10100000000 {
+		t.Errorf("expected the price 101 of fallback market, got %d", fallback.Price)
+	}
+}
+
+func TestAggregateQuorumAndMaxAge(t *testing.T) {
+	old := time.Now().Unix() - 3600
+	markets := []coinpricelisten.PriceMarket{
+		&mockPriceMarket{name: "m1", prices: map[string]float64{"BTC": 100}},
+		&mockPriceMarket{name: "m2", prices: map[string]float64{"BTC": 102}},
+		&mockPriceMarket{name: "m3", prices: map[string]float64{"BTC": 1000}, times: map[string]int64{"BTC": old}},
+		&mockPriceMarket{name: "m4"},
+	}
+	fresh := newAggregateToken("BTC", "")
+	quorum := newAggregateToken("WBTC", "")
+	quorum.MinSources = 3
+	quorum.Price, quorum.Time = 1, old
+	recent := newAggregateToken("RENBTC", "")
+	recent.MinSources = 4
+	recent.MaxAge = 7200
+	recent.Price, recent.Time = 1, old
+	dao := &mockCoinPriceDao{tokens: []*models.TokenBasic{fresh, quorum, recent}}
+	coinpricelisten.NewCoinPriceListen(1, markets, &conf.CoinPriceAggregateConfig{MaxAge: 600}, nil, dao)
+	if fresh.PriceInd != basedef.PRICE_IND_FRESH || fresh.Price != 10100000000 {
+		t.Errorf("expected fresh price 101 without the old market, got %d", fresh.Price)
+	}
+	if marketOf(fresh, "m3").PriceInd != basedef.PRICE_IND_STALE || marketOf(fresh, "m3").ExcludeReason == "" {
+		t.Errorf("old market price should be stale")
+	}
+	if quorum.PriceInd != basedef.PRICE_IND_STALE || quorum.Price != 1 {
+		t.Errorf("token without quorum should keep the stale price, got %d %d", quorum.PriceInd, quorum.Price)
+	}
+	// the old market counts within the max age of token, but the quorum is still not reached
+	if recent.PriceInd != basedef.PRICE_IND_NOT_UPDATED || recent.Price != 1 {
+		t.Errorf("token within max age should not be stale, got %d %d", recent.PriceInd, recent.Price)
+	}
+}
@@ -0,0 +1,154 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"price_notify/basedef"
+	"price_notify/coinpricelisten/binance"
+	"price_notify/conf"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// binanceStreamServer is a stand-in of binance websocket server, it pushes a miniTicker event of
+// every subscribed symbol and can drop the connections.
+type binanceStreamServer struct {
+	server     *httptest.Server
+	price      float64
+	conns      []*websocket.Conn
+	subscribes [][]string
+	lock       sync.Mutex
+}
+
+func newBinanceStreamServer(t *testing.T) *binanceStreamServer {
+	stream := &binanceStreamServer{price: 23417.99}
+	upgrader := websocket.Upgrader{}
+	stream.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade err: %v", err)
+			return
+		}
+		stream.lock.Lock()
+		stream.conns = append(stream.conns, conn)
+		stream.lock.Unlock()
+		for {
+			request := new(binance.StreamRequest)
+			if err := conn.ReadJSON(request); err != nil {
+				return
+			}
+			stream.lock.Lock()
+			stream.subscribes = append(stream.subscribes, request.Params)
+			price := stream.price
+			stream.lock.Unlock()
+			conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"result":null,"id":%d}`, request.Id)))
+			for _, param := range request.Params {
+				symbol := strings.ToUpper(strings.Split(param, "@")[0])
+				event := fmt.Sprintf(`{"e":"24hrMiniTicker","E":1608543923502,"s":"%s","c":"%f","o":"22832.50","h":"23777.00","l":"22590.66","v":"61291.2","q":"1402918291.1"}`, symbol, price)
+				conn.WriteMessage(websocket.TextMessage, []byte(event))
+			}
+		}
+	}))
+	return stream
+}
+
+func (stream *binanceStreamServer) dropConnections(price float64) {
+	stream.lock.Lock()
+	defer stream.lock.Unlock()
+	stream.price = price
+	for _, conn := range stream.conns {
+		conn.Close()
+	}
+	stream.conns = nil
+}
+
+func waitCoinPrice(sdk *binance.BinanceStreamSdk, coin string, price float64) bool {
+	for i := 0; i < 50; i++ {
+		prices, _ := sdk.GetCoinPrice(context.Background(), []string{coin})
+		if priceOf(prices, coin) == price {
+			return true
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	return false
+}
+
+func TestBinanceStreamGetCoinPrice(t *testing.T) {
+	stream := newBinanceStreamServer(t)
+	defer stream.server.Close()
+	sdk := binance.NewBinanceStreamSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_BINANCE,
+		Nodes:      []*conf.Restful{{Url: "ws" + strings.TrimPrefix(stream.server.URL, "http") + "/"}},
+		Stream:     binance.STREAM_MINITICKER,
+		StaleSlot:  5,
+	})
+	defer sdk.Close()
+	if !waitCoinPrice(sdk, "BTCUSDT", 23417.99) {
+		t.Fatal("price of BTCUSDT is not received")
+	}
+	// the coins are subscribed again after reconnecting
+	stream.dropConnections(23500.01)
+	if !waitCoinPrice(sdk, "BTCUSDT", 23500.01) {
+		t.Fatal("price of BTCUSDT is not received after reconnecting")
+	}
+	stream.lock.Lock()
+	defer stream.lock.Unlock()
+	if len(stream.subscribes) < 2 || stream.subscribes[len(stream.subscribes)-1][0] != "btcusdt@miniTicker" {
+		t.Fatalf("unexpected subscribes: %v", stream.subscribes)
+	}
+}
+
+func TestBinanceStreamStalePrice(t *testing.T) {
+	stream := newBinanceStreamServer(t)
+	defer stream.server.Close()
+	sdk := binance.NewBinanceStreamSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_BINANCE,
+		Nodes:      []*conf.Restful{{Url: "ws" + strings.TrimPrefix(stream.server.URL, "http") + "/"}},
+		Stream:     binance.STREAM_MINITICKER,
+		StaleSlot:  1,
+	})
+	defer sdk.Close()
+	if !waitCoinPrice(sdk, "ETHUSDT", 23417.99) {
+		t.Fatal("price of ETHUSDT is not received")
+	}
+	time.Sleep(time.Millisecond * 1100)
+	prices, err := sdk.GetCoinPrice(context.Background(), []string{"ETHUSDT"})
+	if err == nil || len(prices) != 0 {
+		t.Fatalf("stale price should not be returned, prices: %v, err: %v", prices, err)
+	}
+}
+
+func TestBinanceStreamConfiguredCoins(t *testing.T) {
+	stream := newBinanceStreamServer(t)
+	defer stream.server.Close()
+	sdk := binance.NewBinanceStreamSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_BINANCE,
+		Nodes:      []*conf.Restful{{Url: "ws" + strings.TrimPrefix(stream.server.URL, "http") + "/"}},
+		Stream:     binance.STREAM_MINITICKER,
+		StaleSlot:  5,
+		Coins:      []string{"btcusdt"},
+	})
+	defer sdk.Close()
+	// the configured coins are subscribed when the stream connects, before they are queried
+	for i := 0; i < 50; i++ {
+		stream.lock.Lock()
+		subscribed := len(stream.subscribes) > 0
+		stream.lock.Unlock()
+		if subscribed {
+			break
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	time.Sleep(time.Millisecond * 100)
+	prices, err := sdk.GetCoinPrice(context.Background(), []string{"BTCUSDT"})
+	if err != nil || priceOf(prices, "BTCUSDT") != 23417.99 {
+		t.Fatalf("the first query should get the price of configured coin, prices: %v, err: %v", prices, err)
+	}
+	// closing twice does not panic
+	sdk.Close()
+}
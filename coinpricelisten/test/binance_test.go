@@ -0,0 +1,60 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"price_notify/basedef"
+	"price_notify/coinpricelisten/binance"
+	"price_notify/conf"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBinanceVolumeRefresh(t *testing.T) {
+	data, err := basedef.ReadFile("./../../conf/binance_price.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tickersCounter := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/ticker/price":
+			w.Write(data)
+		case "/api/v3/ticker/24hr":
+			atomic.AddInt32(&tickersCounter, 1)
+			symbols := make([]string, 0)
+			json.Unmarshal([]byte(r.URL.Query().Get("symbols")), &symbols)
+			tickers := make([]string, 0, len(symbols))
+			for _, symbol := range symbols {
+				tickers = append(tickers, fmt.Sprintf(`{"symbol":"%s","quoteVolume":"1000"}`, symbol))
+			}
+			fmt.Fprintf(w, "[%s]", strings.Join(tickers, ","))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	sdk := binance.NewBinanceSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_BINANCE,
+		Nodes:      []*conf.Restful{{Url: server.URL + "/"}},
+	})
+	// the volumes are requested once in the slot, and again for a new coin
+	for _, coins := range [][]string{{"BTCUSDT"}, {"BTCUSDT"}, {"BTCUSDT", "ETHUSDT"}, {"ETHUSDT"}} {
+		prices, err := sdk.GetCoinPrice(context.Background(), coins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, coin := range coins {
+			if volume, _ := prices[coin].Volume.Float64(); volume != 1000 {
+				t.Errorf("unexpected volume of %s: %v", coin, volume)
+			}
+		}
+	}
+	if tickersCounter != 2 {
+		t.Errorf("expected 2 requests of 24hr tickers, got %d", tickersCounter)
+	}
+}
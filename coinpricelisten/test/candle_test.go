@@ -0,0 +1,104 @@
+package test
+
+import (
+	"fmt"
+	"price_notify/coinpricelisten"
+	"price_notify/models"
+	"testing"
+	"time"
+)
+
+func TestCandleBuilder(t *testing.T) {
+	builder := coinpricelisten.NewCandleBuilder([]int64{coinpricelisten.CANDLE_PERIOD_MINUTE, coinpricelisten.CANDLE_PERIOD_HOUR})
+	closed := make([]*models.PriceCandle, 0)
+	for _, tick := range []struct{ time, price int64 }{{3600, 100}, {3610, 105}, {3620, 95}, {3650, 102}, {3590, 1}, {3790, 110}} {
+		closed = append(closed, builder.AddPrice("BTC", tick.price, tick.time)...)
+	}
+	// the minute candle at 3600, gap candles at 3660 and 3720
+	if len(closed) != 3 {
+		t.Fatalf("expected 3 closed candles, got %d", len(closed))
+	}
+	first := closed[0]
+	if first.Time != 3600 || first.Open != 100 || first.High != 105 || first.Low != 95 || first.Close != 102 || first.Count != 4 || first.Gap {
+		t.Errorf("unexpected candle: %+v", first)
+	}
+	for i, gap := range closed[1:] {
+		if gap.Time != 3660+int64(i)*60 || !gap.Gap || gap.Count != 0 || gap.Open != 102 || gap.Close != 102 {
+			t.Errorf("unexpected gap candle: %+v", gap)
+		}
+	}
+	tail := builder.Tail("BTC", coinpricelisten.CANDLE_PERIOD_MINUTE)
+	if tail == nil || tail.Time != 3780 || tail.Open != 110 || tail.Count != 1 {
+		t.Errorf("unexpected minute tail: %+v", tail)
+	}
+	tail = builder.Tail("BTC", coinpricelisten.CANDLE_PERIOD_HOUR)
+	if tail == nil || tail.Time != 3600 || tail.High != 110 || tail.Low != 95 || tail.Close != 110 || tail.Count != 5 {
+		t.Errorf("unexpected hour tail: %+v", tail)
+	}
+	if builder.Tail("ETH", coinpricelisten.CANDLE_PERIOD_HOUR) != nil {
+		t.Errorf("there should be no candle of ETH")
+	}
+}
+
+func TestCandleRestart(t *testing.T) {
+	// the prices are added in the same minute
+	if time.Now().Unix()%coinpricelisten.CANDLE_PERIOD_MINUTE >= 58 {
+		time.Sleep(time.Second * 3)
+	}
+	now := time.Now().Unix()
+	minute := now/coinpricelisten.CANDLE_PERIOD_MINUTE*coinpricelisten.CANDLE_PERIOD_MINUTE - 180
+	hour := now / coinpricelisten.CANDLE_PERIOD_HOUR * coinpricelisten.CANDLE_PERIOD_HOUR
+	// the partial minute candle saved 3 minutes before, and the partial hour candle of this hour
+	dao := &mockCoinPriceDao{
+		tokens: []*models.TokenBasic{newAggregateToken("BTC", "")},
+		candles: []*models.PriceCandle{
+			{TokenBasicName: "BTC", Period: coinpricelisten.CANDLE_PERIOD_MINUTE, Time: minute,
+				Open: 100, High: 120, Low: 90, Close: 110, Count: 3, Partial: true},
+			{TokenBasicName: "BTC", Period: coinpricelisten.CANDLE_PERIOD_HOUR, Time: hour,
+				Open: 100, High: 120, Low: 90, Close: 110, Count: 3, Partial: true},
+		},
+	}
+	cpl := coinpricelisten.NewCoinPriceListen(1, newAggregateMarkets(), nil, nil, dao)
+	// the partial candle is closed with the gap candles of the downtime
+	minutes := make([]*models.PriceCandle, 0)
+	for _, candle := range dao.candles {
+		if candle.Period == coinpricelisten.CANDLE_PERIOD_MINUTE {
+			minutes = append(minutes, candle)
+		}
+	}
+	if len(minutes) != 3 || minutes[0].Partial || minutes[0].Count != 3 || minutes[0].Open != 100 ||
+		minutes[0].High != 120 || minutes[0].Low != 90 || minutes[0].Close != 110 || !minutes[1].Gap || minutes[1].Time != minute+60 || !minutes[2].Gap || minutes[2].Close != 110 {
+		t.Fatalf("unexpected minute candles: %+v", minutes)
+	}
+	// the price is added to the partial candle of this hour
+	price := dao.token("BTC").Price
+	if tail := cpl.CandleBuilder().Tail("BTC", coinpricelisten.CANDLE_PERIOD_HOUR); tail == nil || tail.Time != hour ||
+		tail.Open != 100 || tail.High != price || tail.Low != 90 || tail.Close != price || tail.Count != 4 {
+		t.Errorf("unexpected hour tail: %+v", tail)
+	}
+	// the candles are built though the history is not saved
+	dao.historyErr = fmt.Errorf("history is not available")
+	before := cpl.CandleBuilder().Tail("BTC", coinpricelisten.CANDLE_PERIOD_MINUTE)
+	if err := cpl.UpdatePrice(); err == nil {
+		t.Errorf("expected the err of history")
+	}
+	after := cpl.CandleBuilder().Tail("BTC", coinpricelisten.CANDLE_PERIOD_MINUTE)
+	if after.Time != before.Time || after.Count != before.Count+1 || after.Open != before.Open ||
+		after.High != before.High || after.Low != before.Low {
+		t.Errorf("expected the price is added to the candle, got %+v", after)
+	}
+	// the latest candles are saved as partial candles when the listener stops
+	if err := cpl.SaveCandleTails(); err != nil {
+		t.Fatal(err)
+	}
+	partials, _ := dao.GetPartialPriceCandles()
+	if len(partials) != len(coinpricelisten.CANDLE_PERIODS) {
+		t.Fatalf("expected %d partial candles, got %d", len(coinpricelisten.CANDLE_PERIODS), len(partials))
+	}
+	builder := coinpricelisten.NewCandleBuilder(coinpricelisten.CANDLE_PERIODS)
+	builder.Restore(partials)
+	tail := builder.Tail("BTC", coinpricelisten.CANDLE_PERIOD_MINUTE)
+	if expected := cpl.CandleBuilder().Tail("BTC", coinpricelisten.CANDLE_PERIOD_MINUTE); tail == nil || *tail != *expected {
+		t.Errorf("expected the tail is restored, got %+v", tail)
+	}
+}
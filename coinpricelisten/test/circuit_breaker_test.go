@@ -0,0 +1,72 @@
+package test
+
+import (
+	"price_notify/coinpricelisten"
+	"price_notify/conf"
+	"price_notify/models"
+	"testing"
+)
+
+func TestJumpCircuitBreaker(t *testing.T) {
+	m1 := &mockPriceMarket{name: "m1", prices: map[string]float64{"BTC": 100, "ETH": 10}}
+	m2 := &mockPriceMarket{name: "m2", prices: map[string]float64{"ETH": 10}}
+	btc := &models.TokenBasic{Name: "BTC", PriceMarkets: []*models.PriceMarket{{TokenBasicName: "BTC", MarketName: "m1", Name: "BTC"}}}
+	eth := &models.TokenBasic{Name: "ETH", PriceMarkets: []*models.PriceMarket{
+		{TokenBasicName: "ETH", MarketName: "m1", Name: "ETH"},
+		{TokenBasicName: "ETH", MarketName: "m2", Name: "ETH"},
+	}}
+	dao := &mockCoinPriceDao{tokens: []*models.TokenBasic{btc, eth}}
+	cpl := coinpricelisten.NewCoinPriceListen(1, []coinpricelisten.PriceMarket{m1, m2},
+		&conf.CoinPriceAggregateConfig{JumpPercent: 10, JumpConfirmTicks: 3}, nil, dao)
+	events := make([]*coinpricelisten.PriceEvent, 0)
+	cpl.RegisterEventHandler(func(event *coinpricelisten.PriceEvent) {
+		events = append(events, event)
+	})
+	if btc.Price != 10000000000 || eth.Price != 1000000000 {
+		t.Fatalf("first prices should be published, got %d %d", btc.Price, eth.Price)
+	}
+	// a jump from one source is held, a jump agreed by 2 sources is published
+	m1.prices["BTC"], m1.prices["ETH"], m2.prices["ETH"] = 200, 20, 20
+	for tick := 1; tick <= 2; tick++ {
+		if err := cpl.UpdatePrice(); err != nil {
+			t.Fatal(err)
+		}
+		if btc.Price != 10000000000 {
+			t.Errorf("tick %d: jumped price should be held, got %d", tick, btc.Price)
+		}
+	}
+	if eth.Price != 2000000000 {
+		t.Errorf("price confirmed by 2 sources should be published, got %d", eth.Price)
+	}
+	if err := cpl.UpdatePrice(); err != nil {
+		t.Fatal(err)
+	}
+	if btc.Price != 20000000000 {
+		t.Errorf("price held for 3 ticks should be published, got %d", btc.Price)
+	}
+	// a spike going back is discarded
+	m1.prices["BTC"] = 1000
+	cpl.UpdatePrice()
+	m1.prices["BTC"] = 201
+	cpl.UpdatePrice()
+	if btc.Price != 20100000000 {
+		t.Errorf("price in limit should be published, got %d", btc.Price)
+	}
+	eventTypes := make([]string, 0)
+	for _, event := range events {
+		if event.TokenName == "BTC" {
+			eventTypes = append(eventTypes, event.Type)
+		}
+	}
+	expected := []string{coinpricelisten.PRICE_EVENT_HELD, coinpricelisten.PRICE_EVENT_HELD, coinpricelisten.PRICE_EVENT_CONFIRMED,
+		coinpricelisten.PRICE_EVENT_HELD, coinpricelisten.PRICE_EVENT_DISCARDED}
+	if len(eventTypes) != len(expected) {
+		t.Fatalf("expected events %v, got %v", expected, eventTypes)
+	}
+	for i := range expected {
+		if eventTypes[i] != expected[i] {
+			t.Errorf("expected events %v, got %v", expected, eventTypes)
+			break
+		}
+	}
+}
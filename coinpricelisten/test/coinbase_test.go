@@ -0,0 +1,61 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"price_notify/basedef"
+	"price_notify/coinpricelisten/coinbase"
+	"price_notify/conf"
+	"strings"
+	"testing"
+)
+
+func TestCoinbaseGetCoinPrice(t *testing.T) {
+	data, err := basedef.ReadFile("./../../conf/coinbase_price.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tickers := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &tickers); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		product := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/products/"), "/ticker")
+		ticker, ok := tickers[product]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"NotFound"}`))
+			return
+		}
+		w.Write(ticker)
+	}))
+	defer server.Close()
+	sdk := coinbase.NewCoinbaseSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_COINBASE,
+		Nodes:      []*conf.Restful{{Url: server.URL + "/"}},
+	})
+	prices, err := sdk.GetCoinPrice(context.Background(), []string{"BTC-USD", "eth/usd", "UNI-USD", "LINK-USD", "NOTEXIST-USD", "DOT-USD"})
+	partial, ok := err.(*coinbase.PartialError)
+	if !ok {
+		t.Fatalf("expected partial error, got %v", err)
+	}
+	if len(partial.Failed) != 2 || partial.Failed["NOTEXIST-USD"] == nil || partial.Failed["DOT-USD"] == nil {
+		t.Fatalf("unexpected failed coins: %v", partial)
+	}
+	expected := map[string]float64{
+		"BTC-USD":  23421.07,
+		"eth/usd":  624.45,
+		"UNI-USD":  3.6541,
+		"LINK-USD": 12.7201,
+	}
+	if len(prices) != len(expected) {
+		t.Fatalf("expected %d prices, got %v", len(expected), prices)
+	}
+	for coin, price := range expected {
+		if priceOf(prices, coin) != price {
+			t.Errorf("price of %s: expected %v, got %v", coin, price, priceOf(prices, coin))
+		}
+	}
+}
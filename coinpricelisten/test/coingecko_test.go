@@ -0,0 +1,135 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"price_notify/basedef"
+	"price_notify/coinpricelisten/coingecko"
+	"price_notify/conf"
+	"strings"
+	"testing"
+)
+
+func TestCoinGeckoGetCoinPrice(t *testing.T) {
+	server := newFixtureServer(t, map[string]string{
+		"/api/v3/coins/list":   "./../../conf/coingecko_coins.json",
+		"/api/v3/simple/price": "./../../conf/coingecko_price.json",
+	})
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "coingecko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cacheFile := filepath.Join(dir, "coingecko_coins.json")
+	sdk := coingecko.NewCoinGeckoSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_COINGECKO,
+		Nodes:      []*conf.Restful{{Url: server.URL + "/api/v3/"}},
+		CacheFile:  cacheFile,
+	})
+	coins := []string{"bitcoin", "Ethereum", "Polkadot", "uniswap", "Poly", "NOTEXIST"}
+	expected := map[string]float64{
+		"bitcoin":  23419.81,
+		"Ethereum": 624.37,
+		"Polkadot": 5.05,
+		"uniswap":  3.65,
+	}
+	prices, err := sdk.GetCoinPrice(context.Background(), coins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != len(expected) {
+		t.Fatalf("expected %d prices, got %v", len(expected), prices)
+	}
+	for coin, price := range expected {
+		if priceOf(prices, coin) != price {
+			t.Errorf("price of %s: expected %v, got %v", coin, price, priceOf(prices, coin))
+		}
+	}
+	if _, err := os.Stat(cacheFile); err != nil {
+		t.Fatalf("coins cache is not saved: %v", err)
+	}
+	// the coin list is read from the cache file while coins/list is not available
+	noListServer := newFixtureServer(t, map[string]string{
+		"/api/v3/simple/price": "./../../conf/coingecko_price.json",
+	})
+	defer noListServer.Close()
+	cachedSdk := coingecko.NewCoinGeckoSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_COINGECKO,
+		Nodes:      []*conf.Restful{{Url: noListServer.URL + "/api/v3/"}},
+		CacheFile:  cacheFile,
+	})
+	prices, err = cachedSdk.GetCoinPrice(context.Background(), coins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != len(expected) {
+		t.Fatalf("expected %d prices from cached coins, got %v", len(expected), prices)
+	}
+}
+
+func TestCoinGeckoChunkIds(t *testing.T) {
+	ids := []string{"bitcoin", "ethereum", "polkadot", "dogecoin", "uniswap"}
+	chunks := coingecko.ChunkIds(ids, 20)
+	total := 0
+	for _, chunk := range chunks {
+		if joined := strings.Join(chunk, ","); len(joined) > 20 {
+			t.Errorf("chunk %s is longer than 20", joined)
+		}
+		total += len(chunk)
+	}
+	if total != len(ids) || len(chunks) != 3 {
+		t.Fatalf("unexpected chunks: %v", chunks)
+	}
+}
+
+func TestCoinGeckoFailedChunk(t *testing.T) {
+	coins, err := basedef.ReadFile("./../../conf/coingecko_coins.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := basedef.ReadFile("./../../conf/coingecko_price.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	allPrices := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &allPrices); err != nil {
+		t.Fatal(err)
+	}
+	// simple/price answers the requested ids, and fails the chunk of uniswap
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v3/coins/list" {
+			w.Write(coins)
+			return
+		}
+		prices := make(map[string]json.RawMessage)
+		for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+			if id == "uniswap" {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			prices[id] = allPrices[id]
+		}
+		json.NewEncoder(w).Encode(prices)
+	}))
+	defer server.Close()
+	maxIdsLength := coingecko.MAX_IDS_LENGTH
+	coingecko.MAX_IDS_LENGTH = 10
+	defer func() { coingecko.MAX_IDS_LENGTH = maxIdsLength }()
+	sdk := coingecko.NewCoinGeckoSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_COINGECKO,
+		Nodes:      []*conf.Restful{{Url: server.URL + "/api/v3/"}},
+	})
+	prices, err := sdk.GetCoinPrice(context.Background(), []string{"bitcoin", "uniswap", "Polkadot"})
+	if err == nil || !strings.Contains(err.Error(), "uniswap") {
+		t.Errorf("expected the err of uniswap, got %v", err)
+	}
+	if len(prices) != 2 || priceOf(prices, "bitcoin") != 23419.81 || priceOf(prices, "Polkadot") != 5.05 {
+		t.Errorf("expected the prices of the other chunks, got %v", prices)
+	}
+}
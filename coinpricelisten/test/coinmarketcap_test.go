@@ -0,0 +1,130 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/shopspring/decimal"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"price_notify/basedef"
+	"price_notify/coinpricelisten/coinmarketcap"
+	"price_notify/conf"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func newCoinMarketCapServer(t *testing.T, fixture string, listingsCounter *int32) *httptest.Server {
+	data, err := basedef.ReadFile("./../../conf/" + fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listings := make([]*coinmarketcap.Listing, 0)
+	if err := json.Unmarshal(data, &listings); err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/listings/latest":
+			atomic.AddInt32(listingsCounter, 1)
+			json.NewEncoder(w).Encode(&coinmarketcap.ListingsMedia{Data: listings})
+		case "/quotes/latest":
+			quotes := make(map[string]*coinmarketcap.Ticker)
+			for _, id := range strings.Split(r.URL.Query().Get("id"), ",") {
+				for _, listing := range listings {
+					if strconv.Itoa(listing.ID) == id {
+						quotes[id] = &coinmarketcap.Ticker{
+							ID:     listing.ID,
+							Name:   listing.Name,
+							Symbol: listing.Symbol,
+							Quote:  map[string]*coinmarketcap.TickerQuote{"USD": {Price: decimal.NewFromInt(int64(listing.ID))}},
+						}
+					}
+				}
+			}
+			json.NewEncoder(w).Encode(&coinmarketcap.QuotesLatestMedia{Data: quotes})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestCoinMarketCapListingsCache(t *testing.T) {
+	listingsCounter := int32(0)
+	server := newCoinMarketCapServer(t, "coinmarketcap_price.json", &listingsCounter)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "coinmarketcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg := &conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_COINMARKETCAP,
+		Nodes:      []*conf.Restful{{Url: server.URL + "/"}},
+		CacheFile:  filepath.Join(dir, "coinmarketcap_listings.json"),
+	}
+	sdk := coinmarketcap.NewCoinMarketCapSdk(cfg)
+	for i := 0; i < 3; i++ {
+		prices, err := sdk.GetCoinPrice(context.Background(), []string{"Bitcoin", "Ethereum"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if priceOf(prices, "Bitcoin") != 1 || priceOf(prices, "Ethereum") != 1027 {
+			t.Fatalf("unexpected prices: %v", prices)
+		}
+	}
+	if listingsCounter != 1 {
+		t.Fatalf("listings should be downloaded once, downloaded %d times", listingsCounter)
+	}
+	// a missed coin does not refresh the listings within MISS_REFRESH_SLOT
+	prices, err := sdk.GetCoinPrice(context.Background(), []string{"Bitcoin", "NOTEXIST"})
+	if err == nil || priceOf(prices, "Bitcoin") != 1 {
+		t.Fatalf("missed coin should be reported with the other prices, prices: %v, err: %v", prices, err)
+	}
+	stats := sdk.CacheStats()
+	if stats.Refreshes != 1 || stats.Hits != 7 || stats.Misses != 1 || stats.Entries == 0 {
+		t.Fatalf("unexpected cache stats: %+v", stats)
+	}
+	// a new sdk reads the listings from the cache file
+	cachedSdk := coinmarketcap.NewCoinMarketCapSdk(cfg)
+	prices, err = cachedSdk.GetCoinPrice(context.Background(), []string{"Bitcoin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if priceOf(prices, "Bitcoin") != 1 || listingsCounter != 1 {
+		t.Fatalf("listings should be read from cache file, prices: %v, downloaded %d times", prices, listingsCounter)
+	}
+}
+
+func TestCoinMarketCapSelectors(t *testing.T) {
+	listingsCounter := int32(0)
+	server := newCoinMarketCapServer(t, "coinmarketcap_listings.json", &listingsCounter)
+	defer server.Close()
+	sdk := coinmarketcap.NewCoinMarketCapSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_COINMARKETCAP,
+		Nodes:      []*conf.Restful{{Url: server.URL + "/"}},
+	})
+	prices, err := sdk.GetCoinPrice(context.Background(), []string{"id:1027", "slug:binance-coin", "symbol:BTC", "Tether", "symbol:UNI", "slug:uniswap"})
+	if err == nil || !strings.Contains(err.Error(), "symbol UNI is ambiguous") {
+		t.Fatalf("ambiguous symbol should be rejected, err: %v", err)
+	}
+	expected := map[string]float64{
+		"id:1027":           1027,
+		"slug:binance-coin": 1839,
+		"symbol:BTC":        1,
+		"Tether":            825,
+		"slug:uniswap":      7083,
+	}
+	if len(prices) != len(expected) {
+		t.Fatalf("expected %d prices, got %v", len(expected), prices)
+	}
+	for coin, price := range expected {
+		if priceOf(prices, coin) != price {
+			t.Errorf("price of %s: expected %v, got %v", coin, price, priceOf(prices, coin))
+		}
+	}
+}
@@ -27,10 +27,13 @@ func TestListenCoinPrice(t *testing.T) {
 	priceListenConfig := config.CoinPriceListenConfig
 	priceMarkets := make([]coinpricelisten.PriceMarket, 0)
 	for _, cfg := range priceListenConfig {
-		priceMarket := coinpricelisten.NewPriceMarket(cfg)
+		priceMarket, err := coinpricelisten.NewPriceMarket(cfg)
+		if err != nil {
+			panic(err)
+		}
 		priceMarkets = append(priceMarkets, priceMarket)
 	}
-	cpListen := coinpricelisten.NewCoinPriceListen(config.CoinPriceUpdateSlot, priceMarkets, dao)
+	cpListen := coinpricelisten.NewCoinPriceListen(config.CoinPriceUpdateSlot, priceMarkets, config.CoinPriceAggregateConfig, config.PriceHistoryConfig, dao)
 	cpListen.ListenPrice()
 }
 
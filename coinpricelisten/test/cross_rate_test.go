@@ -0,0 +1,65 @@
+package test
+
+import (
+	"price_notify/basedef"
+	"price_notify/coinpricelisten"
+	"price_notify/coinpricelisten/binance"
+	"price_notify/conf"
+	"price_notify/models"
+	"testing"
+)
+
+func TestCrossRatePrice(t *testing.T) {
+	server := newFixtureServer(t, map[string]string{
+		"/api/v3/ticker/price": "./../../conf/binance_price.json",
+	})
+	defer server.Close()
+	market := binance.NewBinanceSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_BINANCE,
+		Nodes:      []*conf.Restful{{Url: server.URL + "/"}},
+	})
+	dao := &mockCoinPriceDao{
+		tokens: []*models.TokenBasic{
+			{
+				Name: "BTC",
+				PriceMarkets: []*models.PriceMarket{
+					{TokenBasicName: "BTC", MarketName: basedef.MARKET_BINANCE, Name: "BTCUSDT"},
+				},
+			},
+			{
+				Name: "LTC",
+				PriceMarkets: []*models.PriceMarket{
+					{TokenBasicName: "LTC", MarketName: basedef.MARKET_BINANCE, Name: "LTCBTC*BTCUSDT"},
+				},
+			},
+			{
+				Name: "QTUM",
+				PriceMarkets: []*models.PriceMarket{
+					{TokenBasicName: "QTUM", MarketName: basedef.MARKET_BINANCE, Name: "QTUMETH * ETHUSDT"},
+				},
+			},
+			{
+				Name: "NOTEXIST",
+				PriceMarkets: []*models.PriceMarket{
+					{TokenBasicName: "NOTEXIST", MarketName: basedef.MARKET_BINANCE, Name: "NOTEXISTBTC*BTCUSDT"},
+				},
+			},
+		},
+	}
+	coinpricelisten.NewCoinPriceListen(1, []coinpricelisten.PriceMarket{market}, nil, nil, dao)
+	// BTCUSDT 23417.99, LTCBTC 0.00468300, QTUMETH 0.00428600, ETHUSDT 624.28
+	expected := map[string]int64{
+		"BTC":  2341799000000,
+		"LTC":  10966644717,
+		"QTUM": 267566408,
+	}
+	for name, price := range expected {
+		token := dao.token(name)
+		if token.PriceInd != 1 || token.Price != price {
+			t.Errorf("price of %s: expected %d, got %d", name, price, token.Price)
+		}
+	}
+	if token := dao.token("NOTEXIST"); token.PriceInd != 0 {
+		t.Errorf("price of NOTEXIST should not be updated")
+	}
+}
@@ -0,0 +1,27 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"price_notify/basedef"
+	"testing"
+)
+
+// newFixtureServer serves the fixture files by the url paths of routes
+func newFixtureServer(t *testing.T, routes map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fixture, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := basedef.ReadFile(fixture)
+		if err != nil {
+			t.Errorf("read fixture %s err: %v", fixture, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}))
+}
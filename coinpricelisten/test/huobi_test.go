@@ -0,0 +1,70 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"price_notify/basedef"
+	"price_notify/coinpricelisten"
+	"price_notify/coinpricelisten/huobi"
+	"price_notify/conf"
+	"testing"
+)
+
+func TestHuobiGetCoinPrice(t *testing.T) {
+	server := newFixtureServer(t, map[string]string{
+		"/market/tickers": "./../../conf/huobi_price.json",
+	})
+	defer server.Close()
+	sdk := huobi.NewHuobiSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_HUOBI,
+		Nodes: []*conf.Restful{
+			{Url: "http://127.0.0.1:1/"},
+			{Url: server.URL + "/"},
+		},
+	})
+	prices, err := sdk.GetCoinPrice(context.Background(), []string{"BTCUSDT", "eth/usdt", "DOT-USDT", "NOTEXIST"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]float64{
+		"BTCUSDT":  23418.42,
+		"eth/usdt": 624.35,
+		"DOT-USDT": 5.0531,
+	}
+	if len(prices) != len(expected) {
+		t.Fatalf("expected %d prices, got %v", len(expected), prices)
+	}
+	for coin, price := range expected {
+		if priceOf(prices, coin) != price {
+			t.Errorf("price of %s: expected %v, got %v", coin, price, priceOf(prices, coin))
+		}
+	}
+	if prices["BTCUSDT"].Volume.String() != "893471102.2213587" || prices["BTCUSDT"].Time != 1608543923 {
+		t.Errorf("unexpected volume or time of BTCUSDT: %+v", prices["BTCUSDT"])
+	}
+}
+
+func TestHuobiErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"error","err-code":"invalid-parameter","err-msg":"invalid symbol"}`))
+	}))
+	defer server.Close()
+	sdk := huobi.NewHuobiSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_HUOBI,
+		Nodes:      []*conf.Restful{{Url: server.URL + "/"}},
+	})
+	if _, err := sdk.GetCoinPrice(context.Background(), []string{"BTCUSDT"}); err == nil {
+		t.Fatal("expected error for error status")
+	}
+}
+
+func TestNewPriceMarketHuobi(t *testing.T) {
+	market, err := coinpricelisten.NewPriceMarket(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_HUOBI,
+		Nodes:      []*conf.Restful{{Url: "https://api.huobi.pro/"}},
+	})
+	if err != nil || market.GetMarketName() != basedef.MARKET_HUOBI {
+		t.Fatalf("huobi price market is not created, err: %v", err)
+	}
+}
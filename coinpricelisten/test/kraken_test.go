@@ -0,0 +1,69 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"price_notify/basedef"
+	"price_notify/coinpricelisten/kraken"
+	"price_notify/conf"
+	"sync/atomic"
+	"testing"
+)
+
+func TestKrakenGetCoinPrice(t *testing.T) {
+	fixtures := newFixtureServer(t, map[string]string{
+		"/0/public/AssetPairs": "./../../conf/kraken_assetpairs.json",
+		"/0/public/Ticker":     "./../../conf/kraken_price.json",
+	})
+	defer fixtures.Close()
+	assetPairsCounter := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/0/public/AssetPairs" {
+			atomic.AddInt32(&assetPairsCounter, 1)
+		}
+		fixtures.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	sdk := kraken.NewKrakenSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_KRAKEN,
+		Nodes:      []*conf.Restful{{Url: server.URL + "/"}},
+	})
+	expected := map[string]float64{
+		"XBTUSD":   23420.1,
+		"eth/usd":  624.41,
+		"XXBTZUSD": 23420.1,
+		"DOTUSD":   5.0536,
+	}
+	for i := 0; i < 2; i++ {
+		prices, err := sdk.GetCoinPrice(context.Background(), []string{"XBTUSD", "eth/usd", "XXBTZUSD", "DOTUSD", "NOTEXIST"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(prices) != len(expected) {
+			t.Fatalf("expected %d prices, got %v", len(expected), prices)
+		}
+		for coin, price := range expected {
+			if priceOf(prices, coin) != price {
+				t.Errorf("price of %s: expected %v, got %v", coin, price, priceOf(prices, coin))
+			}
+		}
+	}
+	if assetPairsCounter != 1 {
+		t.Errorf("asset pairs should be read once, read %d times", assetPairsCounter)
+	}
+}
+
+func TestKrakenError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"error":["EGeneral:Temporary lockout"]}`))
+	}))
+	defer server.Close()
+	sdk := kraken.NewKrakenSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_KRAKEN,
+		Nodes:      []*conf.Restful{{Url: server.URL + "/"}},
+	})
+	if _, err := sdk.GetCoinPrice(context.Background(), []string{"XBTUSD"}); err == nil {
+		t.Fatal("expected error for non-empty error")
+	}
+}
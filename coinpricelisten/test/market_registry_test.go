@@ -0,0 +1,80 @@
+package test
+
+import (
+	"fmt"
+	"price_notify/basedef"
+	"price_notify/coinpricelisten"
+	"price_notify/conf"
+	"strings"
+	"testing"
+)
+
+func TestRegisterPriceMarket(t *testing.T) {
+	coinpricelisten.RegisterPriceMarket("mock", &coinpricelisten.PriceMarketFactory{
+		Schema: &coinpricelisten.PriceMarketSchema{RequireNodes: true},
+		Validate: func(cfg *conf.CoinPriceListenConfig) error {
+			if cfg.Nodes[0].Key == "invalid" {
+				return fmt.Errorf("invalid key")
+			}
+			return nil
+		},
+		New: func(cfg *conf.CoinPriceListenConfig) (coinpricelisten.PriceMarket, error) {
+			return &mockPriceMarket{name: cfg.MarketName}, nil
+		},
+	})
+	defer coinpricelisten.UnregisterPriceMarket("mock")
+	names := coinpricelisten.PriceMarketNames()
+	if !strings.Contains(strings.Join(names, ","), "mock") {
+		t.Fatalf("mock is not registered: %v", names)
+	}
+	market, err := coinpricelisten.NewPriceMarket(&conf.CoinPriceListenConfig{
+		MarketName: "mock",
+		Nodes:      []*conf.Restful{{Url: "http://localhost/"}},
+	})
+	if err != nil || market.GetMarketName() != "mock" {
+		t.Fatalf("mock price market is not created, err: %v", err)
+	}
+	_, err = coinpricelisten.NewPriceMarket(&conf.CoinPriceListenConfig{
+		MarketName: "mock",
+		Nodes:      []*conf.Restful{{Url: "http://localhost/", Key: "invalid"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid key") {
+		t.Fatalf("expected validate error, got %v", err)
+	}
+}
+
+func TestValidatePriceMarket(t *testing.T) {
+	invalids := map[string]*conf.CoinPriceListenConfig{
+		"unknown price market: huobix": {MarketName: "huobix"},
+		"there is no node":             {MarketName: basedef.MARKET_BINANCE},
+		"key of node 0 is empty": {
+			MarketName: basedef.MARKET_COINMARKETCAP,
+			Nodes:      []*conf.Restful{{Url: "https://pro-api.coinmarketcap.com/v1/cryptocurrency/"}},
+		},
+		"unknown Stream trade": {
+			MarketName: basedef.MARKET_BINANCE,
+			Nodes:      []*conf.Restful{{Url: "wss://stream.binance.com:9443/"}},
+			Stream:     "trade",
+		},
+		"Coins is not supported": {
+			MarketName: basedef.MARKET_HUOBI,
+			Nodes:      []*conf.Restful{{Url: "https://api.huobi.pro/"}},
+			Coins:      []string{"btcusdt"},
+		},
+		"CacheFile and CacheRefreshSlot are not supported": {
+			MarketName: basedef.MARKET_HUOBI,
+			Nodes:      []*conf.Restful{{Url: "https://api.huobi.pro/"}},
+			CacheFile:  "huobi.json",
+		},
+	}
+	for expected, cfg := range invalids {
+		err := coinpricelisten.ValidatePriceMarket(cfg)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %s, got %v", expected, err)
+		}
+	}
+	err := coinpricelisten.ValidatePriceMarket(&conf.CoinPriceListenConfig{MarketName: "huobix"})
+	if !strings.Contains(err.Error(), basedef.MARKET_BINANCE+", "+basedef.MARKET_COINBASE) {
+		t.Errorf("known price markets should be listed, got %v", err)
+	}
+}
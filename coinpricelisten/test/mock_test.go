@@ -0,0 +1,166 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"price_notify/models"
+	"time"
+)
+
+// mockCoinPriceDao keeps the tokens in memory and records the saved tokens
+type mockCoinPriceDao struct {
+	tokens    []*models.TokenBasic
+	saved     [][]*models.TokenBasic
+	histories []*models.PriceHistory
+	candles   []*models.PriceCandle
+	// the error of adding histories
+	historyErr error
+}
+
+func (dao *mockCoinPriceDao) GetTokens() ([]*models.TokenBasic, error) {
+	return dao.tokens, nil
+}
+
+func (dao *mockCoinPriceDao) AddTokens(tokens []*models.TokenBasic) error {
+	dao.tokens = append(dao.tokens, tokens...)
+	return nil
+}
+
+func (dao *mockCoinPriceDao) SavePrices(tokens []*models.TokenBasic) error {
+	dao.saved = append(dao.saved, tokens)
+	return nil
+}
+
+func (dao *mockCoinPriceDao) AddPriceHistories(histories []*models.PriceHistory) error {
+	if dao.historyErr != nil {
+		return dao.historyErr
+	}
+	dao.histories = append(dao.histories, histories...)
+	return nil
+}
+
+func (dao *mockCoinPriceDao) GetPriceHistories(bucket int64, start int64, end int64) ([]*models.PriceHistory, error) {
+	histories := make([]*models.PriceHistory, 0)
+	for _, history := range dao.histories {
+		if history.Bucket == bucket && history.Time >= start && history.Time < end {
+			histories = append(histories, history)
+		}
+	}
+	return histories, nil
+}
+
+func (dao *mockCoinPriceDao) GetLastPriceHistoryTime(bucket int64) (int64, error) {
+	last := int64(0)
+	for _, history := range dao.histories {
+		if history.Bucket == bucket && history.Time > last {
+			last = history.Time
+		}
+	}
+	return last, nil
+}
+
+func (dao *mockCoinPriceDao) DeletePriceHistories(bucket int64, before int64) error {
+	histories := make([]*models.PriceHistory, 0)
+	for _, history := range dao.histories {
+		if history.Bucket != bucket || history.Time >= before {
+			histories = append(histories, history)
+		}
+	}
+	dao.histories = histories
+	return nil
+}
+
+func (dao *mockCoinPriceDao) AddPriceCandles(candles []*models.PriceCandle) error {
+	for _, candle := range candles {
+		replaced := false
+		for i, old := range dao.candles {
+			if old.TokenBasicName == candle.TokenBasicName && old.Period == candle.Period && old.Time == candle.Time {
+				dao.candles[i] = candle
+				replaced = true
+			}
+		}
+		if !replaced {
+			dao.candles = append(dao.candles, candle)
+		}
+	}
+	return nil
+}
+
+func (dao *mockCoinPriceDao) GetPartialPriceCandles() ([]*models.PriceCandle, error) {
+	candles := make([]*models.PriceCandle, 0)
+	for _, candle := range dao.candles {
+		if candle.Partial {
+			candles = append(candles, candle)
+		}
+	}
+	return candles, nil
+}
+
+func (dao *mockCoinPriceDao) Name() string {
+	return "mock"
+}
+
+func (dao *mockCoinPriceDao) token(name string) *models.TokenBasic {
+	for _, token := range dao.tokens {
+		if token.Name == name {
+			return token
+		}
+	}
+	return nil
+}
+
+// mockPriceMarket returns the fixed prices and volumes of coins
+type mockPriceMarket struct {
+	name    string
+	prices  map[string]float64
+	volumes map[string]float64
+	times   map[string]int64
+	// delay of every query, the query does not give up when ctx is done
+	delay time.Duration
+	// the coins whose query waits until ctx is done, the prices of other coins are returned with the error
+	slowCoins []string
+}
+
+func (market *mockPriceMarket) GetMarketName() string {
+	return market.name
+}
+
+func (market *mockPriceMarket) GetCoinPrice(ctx context.Context, coins []string) (map[string]*models.CoinPrice, error) {
+	time.Sleep(market.delay)
+	if market.prices == nil {
+		return nil, fmt.Errorf("market %s is not available", market.name)
+	}
+	coinPrice := make(map[string]*models.CoinPrice)
+	for _, coin := range coins {
+		if price, ok := market.prices[coin]; ok {
+			coinTime, ok := market.times[coin]
+			if !ok {
+				coinTime = time.Now().Unix()
+			}
+			coinPrice[coin] = &models.CoinPrice{
+				Price:  decimal.NewFromFloat(price),
+				Volume: decimal.NewFromFloat(market.volumes[coin]),
+				Time:   coinTime,
+			}
+		}
+	}
+	for _, coin := range coins {
+		for _, slowCoin := range market.slowCoins {
+			if coin == slowCoin {
+				delete(coinPrice, coin)
+				<-ctx.Done()
+				return coinPrice, fmt.Errorf("get coin price of %s: %v", coin, ctx.Err())
+			}
+		}
+	}
+	return coinPrice, nil
+}
+
+func priceOf(prices map[string]*models.CoinPrice, coin string) float64 {
+	if prices[coin] == nil {
+		return 0
+	}
+	price, _ := prices[coin].Price.Float64()
+	return price
+}
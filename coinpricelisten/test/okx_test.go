@@ -0,0 +1,53 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"price_notify/basedef"
+	"price_notify/coinpricelisten/okx"
+	"price_notify/conf"
+	"testing"
+)
+
+func TestOkxGetCoinPrice(t *testing.T) {
+	server := newFixtureServer(t, map[string]string{
+		"/api/v5/market/tickers": "./../../conf/okx_price.json",
+	})
+	defer server.Close()
+	sdk := okx.NewOkxSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_OKX,
+		Nodes:      []*conf.Restful{{Url: server.URL + "/"}},
+	})
+	prices, err := sdk.GetCoinPrice(context.Background(), []string{"BTC-USDT", "eth/usdt", "OKB_USDT", "NEW-USDT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]float64{
+		"BTC-USDT": 23416.8,
+		"eth/usdt": 624.31,
+		"OKB_USDT": 5.7712,
+	}
+	if len(prices) != len(expected) {
+		t.Fatalf("expected %d prices, got %v", len(expected), prices)
+	}
+	for coin, price := range expected {
+		if priceOf(prices, coin) != price {
+			t.Errorf("price of %s: expected %v, got %v", coin, price, priceOf(prices, coin))
+		}
+	}
+}
+
+func TestOkxErrorCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":"50011","msg":"Requests too frequent.","data":[]}`))
+	}))
+	defer server.Close()
+	sdk := okx.NewOkxSdk(&conf.CoinPriceListenConfig{
+		MarketName: basedef.MARKET_OKX,
+		Nodes:      []*conf.Restful{{Url: server.URL + "/"}},
+	})
+	if _, err := sdk.GetCoinPrice(context.Background(), []string{"BTC-USDT"}); err == nil {
+		t.Fatal("expected error for non-zero code")
+	}
+}
@@ -0,0 +1,79 @@
+package test
+
+import (
+	"price_notify/coinpricelisten"
+	"price_notify/conf"
+	"price_notify/models"
+	"testing"
+)
+
+func historiesOf(dao *mockCoinPriceDao, bucket int64, market string) []*models.PriceHistory {
+	histories := make([]*models.PriceHistory, 0)
+	for _, history := range dao.histories {
+		if history.Bucket == bucket && history.MarketName == market {
+			histories = append(histories, history)
+		}
+	}
+	return histories
+}
+
+func TestPriceHistory(t *testing.T) {
+	token := newAggregateToken("BTC", "")
+	dao := &mockCoinPriceDao{tokens: []*models.TokenBasic{token}}
+	cpl := coinpricelisten.NewCoinPriceListen(1, newAggregateMarkets(), nil,
+		&conf.PriceHistoryConfig{RawRetention: 120, MinuteRetention: 3600}, dao)
+	// a sample of the aggregated price and the samples of 4 markets are appended in every update
+	if len(dao.histories) != 5 || len(historiesOf(dao, coinpricelisten.HISTORY_BUCKET_RAW, "")) != 1 {
+		t.Fatalf("expected 5 histories, got %d", len(dao.histories))
+	}
+	// samples of 2 minutes and a sample of the incomplete minute
+	dao.histories = nil
+	for i, price := range []int64{100, 101, 103, 200, 300} {
+		dao.AddPriceHistories([]*models.PriceHistory{{TokenBasicName: "BTC", Time: 3600 + int64(i)*30, Price: price, Count: 1}})
+	}
+	if err := cpl.RollupPriceHistory(3720); err != nil {
+		t.Fatal(err)
+	}
+	minutes := historiesOf(dao, coinpricelisten.HISTORY_BUCKET_MINUTE, "")
+	if len(minutes) != 2 || minutes[0].Time != 3600 || minutes[0].Price != 100 || minutes[0].Count != 2 ||
+		minutes[1].Time != 3660 || minutes[1].Price != 152 || minutes[1].Count != 2 {
+		t.Fatalf("unexpected minute buckets: %+v %+v", minutes[0], minutes[1])
+	}
+	// rollup again does not add the buckets twice, the samples older than the retention are deleted
+	if err := cpl.RollupPriceHistory(3840); err != nil {
+		t.Fatal(err)
+	}
+	minutes = historiesOf(dao, coinpricelisten.HISTORY_BUCKET_MINUTE, "")
+	if len(minutes) != 3 || minutes[2].Time != 3720 || minutes[2].Price != 300 {
+		t.Errorf("expected 3 minute buckets, got %d", len(minutes))
+	}
+	if raws := historiesOf(dao, coinpricelisten.HISTORY_BUCKET_RAW, ""); len(raws) != 1 || raws[0].Time != 3720 {
+		t.Errorf("expected the samples before 3720 are deleted, got %d", len(raws))
+	}
+	// the minute buckets are rolled up into hour buckets weighted by count
+	if err := cpl.RollupPriceHistory(7200); err != nil {
+		t.Fatal(err)
+	}
+	hours := historiesOf(dao, coinpricelisten.HISTORY_BUCKET_HOUR, "")
+	if len(hours) != 1 || hours[0].Time != 3600 || hours[0].Count != 5 || hours[0].Price != 161 {
+		t.Errorf("unexpected hour buckets: %+v", hours)
+	}
+}
+
+func TestPriceHistorySampleTime(t *testing.T) {
+	markets := newAggregateMarkets()
+	// the time of m1 lags behind the other markets and does not advance
+	markets[0].(*mockPriceMarket).times = map[string]int64{"BTC": 1000}
+	dao := &mockCoinPriceDao{tokens: []*models.TokenBasic{newAggregateToken("BTC", "")}}
+	cpl := coinpricelisten.NewCoinPriceListen(1, markets, nil, nil, dao)
+	samples := historiesOf(dao, coinpricelisten.HISTORY_BUCKET_RAW, "m1")
+	if len(samples) != 1 || samples[0].Time != historiesOf(dao, coinpricelisten.HISTORY_BUCKET_RAW, "m2")[0].Time {
+		t.Fatalf("expected the sample of m1 is stamped with the update time, got %+v", samples)
+	}
+	if err := cpl.UpdatePrice(); err != nil {
+		t.Fatal(err)
+	}
+	if samples := historiesOf(dao, coinpricelisten.HISTORY_BUCKET_RAW, "m1"); len(samples) != 1 {
+		t.Errorf("expected the price of m1 is not sampled again, got %d samples", len(samples))
+	}
+}
@@ -0,0 +1,69 @@
+package test
+
+import (
+	"github.com/shopspring/decimal"
+	"price_notify/basedef"
+	"price_notify/coinpricelisten"
+	"price_notify/models"
+	"testing"
+)
+
+func TestPriceToInt64(t *testing.T) {
+	expected := map[string]int64{
+		"23418.42":             2341842000000,
+		"0.000000015":          2,
+		"0.000000025":          2,
+		"0.0000000251":         3,
+		"-0.000000035":         -4,
+		"92233720368.54775807": 9223372036854775807,
+	}
+	for price, scaled := range expected {
+		result, err := basedef.PriceToInt64(decimal.RequireFromString(price), basedef.PRICE_DECIMALS)
+		if err != nil || result != scaled {
+			t.Errorf("price %s: expected %d, got %d, err: %v", price, scaled, result, err)
+		}
+	}
+	if _, err := basedef.PriceToInt64(decimal.RequireFromString("92233720368.54775808"), basedef.PRICE_DECIMALS); err == nil {
+		t.Errorf("expected overflow error")
+	}
+}
+
+func TestTokenPrecision(t *testing.T) {
+	market := &mockPriceMarket{name: "m1", prices: map[string]float64{"BTC": 23417.99, "DOGE": 0.0036123456789}}
+	dao := &mockCoinPriceDao{
+		tokens: []*models.TokenBasic{
+			{Name: "BTC", PriceMarkets: []*models.PriceMarket{{TokenBasicName: "BTC", MarketName: "m1", Name: "BTC"}}},
+			{Name: "DOGE", Precision: 12, PriceMarkets: []*models.PriceMarket{{TokenBasicName: "DOGE", MarketName: "m1", Name: "DOGE"}}},
+			{Name: "WBTC", Property: basedef.TOKEN_PROPERTY_DISABLED, Price: 1, PriceMarkets: []*models.PriceMarket{{TokenBasicName: "WBTC", MarketName: "m1", Name: "BTC"}}},
+		},
+	}
+	coinpricelisten.NewCoinPriceListen(1, []coinpricelisten.PriceMarket{market}, nil, nil, dao)
+	expected := map[string]int64{
+		"BTC":  2341799000000,
+		"DOGE": 3612345679,
+		"WBTC": 1,
+	}
+	for name, price := range expected {
+		if token := dao.token(name); token.Price != price {
+			t.Errorf("price of %s: expected %d, got %d", name, price, token.Price)
+		}
+	}
+}
+
+func TestAggregateBeforeRounding(t *testing.T) {
+	markets := []coinpricelisten.PriceMarket{
+		&mockPriceMarket{name: "m1", prices: map[string]float64{"BTC": 1.004}},
+		&mockPriceMarket{name: "m2", prices: map[string]float64{"BTC": 1.004}},
+		&mockPriceMarket{name: "m3", prices: map[string]float64{"BTC": 1.008}},
+	}
+	token := &models.TokenBasic{Name: "BTC", Precision: 2}
+	for _, market := range []string{"m1", "m2", "m3"} {
+		token.PriceMarkets = append(token.PriceMarkets, &models.PriceMarket{TokenBasicName: "BTC", MarketName: market, Name: "BTC"})
+	}
+	dao := &mockCoinPriceDao{tokens: []*models.TokenBasic{token}}
+	coinpricelisten.NewCoinPriceListen(1, markets, nil, nil, dao)
+	// the mean 1.00533 is rounded once, rounding the market prices first gives 1.00
+	if token.Price != 101 || marketOf(token, "m1").Price != 100 {
+		t.Errorf("expected price 101, got %d", token.Price)
+	}
+}
@@ -0,0 +1,41 @@
+package test
+
+import (
+	"context"
+	"price_notify/coinpricelisten"
+	"price_notify/models"
+	"testing"
+	"time"
+)
+
+func TestTimeoutPriceMarket(t *testing.T) {
+	hung := coinpricelisten.NewTimeoutPriceMarket(&mockPriceMarket{name: "m1", prices: map[string]float64{"BTC": 100}, delay: time.Second * 5}, time.Millisecond*100)
+	fast := coinpricelisten.NewTimeoutPriceMarket(&mockPriceMarket{name: "m2", prices: map[string]float64{"BTC": 102}}, time.Millisecond*100)
+	start := time.Now()
+	prices, err := hung.GetCoinPrice(context.Background(), []string{"BTC"})
+	if err == nil || len(prices) != 0 || time.Since(start) > time.Second {
+		t.Errorf("expected timeout error, got %v %v", prices, err)
+	}
+	// markets are queried concurrently, a hung market does not stall the others
+	token := &models.TokenBasic{Name: "BTC", PriceMarkets: []*models.PriceMarket{
+		{TokenBasicName: "BTC", MarketName: "m1", Name: "BTC"},
+		{TokenBasicName: "BTC", MarketName: "m2", Name: "BTC"},
+	}}
+	dao := &mockCoinPriceDao{tokens: []*models.TokenBasic{token}}
+	start = time.Now()
+	coinpricelisten.NewCoinPriceListen(1, []coinpricelisten.PriceMarket{hung, fast}, nil, nil, dao)
+	if time.Since(start) > time.Second {
+		t.Errorf("update is stalled by the hung market")
+	}
+	if token.Price != 10200000000 || marketOf(token, "m1").PriceInd != 0 {
+		t.Errorf("expected the price of fast market, got %d", token.Price)
+	}
+}
+
+func TestTimeoutPriceMarketPartial(t *testing.T) {
+	slow := coinpricelisten.NewTimeoutPriceMarket(&mockPriceMarket{name: "m1", prices: map[string]float64{"BTC": 100, "ETH": 10}, slowCoins: []string{"ETH"}}, time.Millisecond*100)
+	prices, err := slow.GetCoinPrice(context.Background(), []string{"BTC", "ETH"})
+	if err == nil || priceOf(prices, "BTC") != 100 || prices["ETH"] != nil {
+		t.Errorf("expected the price of BTC with timeout error, got %v %v", prices, err)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+package coinpricelisten
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"price_notify/models"
+	"time"
+)
+
+var (
+	// seconds to wait for a price market in an update
+	DEFAULT_MARKET_TIMEOUT = int64(10)
+	// the time to wait for the prices a price market got before its query is timed out
+	MARKET_CANCEL_GRACE = time.Millisecond * 500
+)
+
+// TimeoutPriceMarket limits the time of every query of a price market. When the time is out, the query is given up
+// and the prices the price market got before are returned with the error. It returns after MARKET_CANCEL_GRACE
+// even if the price market does not give up the query.
+type TimeoutPriceMarket struct {
+	priceMarket PriceMarket
+	timeout     time.Duration
+}
+
+func NewTimeoutPriceMarket(priceMarket PriceMarket, timeout time.Duration) *TimeoutPriceMarket {
+	return &TimeoutPriceMarket{
+		priceMarket: priceMarket,
+		timeout:     timeout,
+	}
+}
+
+// limitPriceMarket limits the queries of a price market by timeout seconds, DEFAULT_MARKET_TIMEOUT is used
+// if timeout is 0. A price market which is limited already is returned as it is.
+func limitPriceMarket(priceMarket PriceMarket, timeout int64) PriceMarket {
+	if _, ok := priceMarket.(*TimeoutPriceMarket); ok {
+		return priceMarket
+	}
+	if timeout == 0 {
+		timeout = DEFAULT_MARKET_TIMEOUT
+	}
+	return NewTimeoutPriceMarket(priceMarket, time.Second*time.Duration(timeout))
+}
+
+// PriceMarket returns the price market which is limited
+func (market *TimeoutPriceMarket) PriceMarket() PriceMarket {
+	return market.priceMarket
+}
+
+func (market *TimeoutPriceMarket) GetMarketName() string {
+	return market.priceMarket.GetMarketName()
+}
+
+type coinPriceResult struct {
+	coinPrices map[string]*models.CoinPrice
+	err        error
+}
+
+func (market *TimeoutPriceMarket) GetCoinPrice(ctx context.Context, coins []string) (map[string]*models.CoinPrice, error) {
+	ctx, cancel := context.WithTimeout(ctx, market.timeout)
+	defer cancel()
+	result := make(chan *coinPriceResult, 1)
+	go func() {
+		coinPrices, err := market.priceMarket.GetCoinPrice(ctx, coins)
+		result <- &coinPriceResult{coinPrices: coinPrices, err: err}
+	}()
+	select {
+	case r := <-result:
+		return r.coinPrices, r.err
+	case <-ctx.Done():
+	}
+	select {
+	case r := <-result:
+		if r.err == nil {
+			r.err = fmt.Errorf("get coin price of market %s: %v", market.GetMarketName(), ctx.Err())
+		}
+		return r.coinPrices, r.err
+	case <-time.After(MARKET_CANCEL_GRACE):
+		return nil, fmt.Errorf("get coin price of market %s: %v", market.GetMarketName(), ctx.Err())
+	}
+}
+
+// Close closes the price market if it can be closed
+func (market *TimeoutPriceMarket) Close() error {
+	if closer, ok := market.priceMarket.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
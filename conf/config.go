@@ -37,19 +37,81 @@ type Restful struct {
 }
 
 type CoinPriceListenConfig struct {
-	MarketName string
-	Nodes      []*Restful
+	MarketName       string
+	Nodes            []*Restful
+	CacheFile        string
+	CacheRefreshSlot int64
+	Stream           string
+	StaleSlot        int64
+	// coins subscribed by a stream market when it connects, other coins are subscribed when they are queried
+	Coins []string
+	// seconds to wait for the prices of the market in an update, 10 by default
+	Timeout int64
 }
 
+type CoinPriceAggregateConfig struct {
+	// mean, median, trimmed_mean, mad, vwap, weighted or primary, mean by default
+	Strategy string
+	// percent of prices trimmed from each side by trimmed_mean
+	TrimPercent int64
+	// prices deviating from the median by more than MadThreshold scaled MAD are rejected by mad
+	MadThreshold float64
+	// minimum number of market prices to publish the price of token, 1 by default
+	MinSources int64
+	// max age in seconds of market prices, a token price older than it is stale, 0 means no limit
+	MaxAge int64
+	// a price changing from the last one by more than JumpPercent is held until it is confirmed, 0 means no limit
+	JumpPercent float64
+	// consecutive updates to confirm a held price, 3 by default
+	JumpConfirmTicks int64
+}
+
+// PriceHistoryConfig sets how long the price history is kept, a negative retention keeps it forever
+type PriceHistoryConfig struct {
+	// seconds between two rollups of the price history, 600 by default
+	RollupSlot int64
+	// seconds to keep the samples of every update, 1 day by default
+	RawRetention int64
+	// seconds to keep the 1 minute buckets, 7 days by default
+	MinuteRetention int64
+	// seconds to keep the 1 hour buckets, 90 days by default
+	HourRetention int64
+	// seconds to keep the 1 day buckets, forever by default
+	DayRetention int64
+}
+
+// NotifyChannelConfig is a channel which the notifications are sent to. A notification is routed to the channel if
+// its token is in Tokens and its severity is in Severities, an empty list matches all. The notifications are only
+// logged if Switch is off.
+type NotifyChannelConfig struct {
+	Name       string
+	// one of NOTIFY_CHANNEL_*
+	Type       string
+	Switch     bool
+	Node       *Restful
+	Tokens     []string
+	Severities []string
+}
+
+// PriceNotifyConfig sets the channels of notifications, the notifications not routed to any of Channels are sent to
+// the DingTalk robot of Node as the fallback channel.
 type PriceNotifyConfig struct {
 	Switch bool
 	Node      *Restful
+	Channels  []*NotifyChannelConfig
+	// the number of notifications held back which are collapsed into a flapping notification, 2 by default
+	FlapNotifies int64
+	// a rule triggered FlapTriggers times in FlapWindow seconds is flapping until the window ends, 3 and 3600 by default
+	FlapTriggers int64
+	FlapWindow   int64
 }
 
 type Config struct {
 	Server string
 	CoinPriceUpdateSlot   int64
 	CoinPriceListenConfig []*CoinPriceListenConfig
+	CoinPriceAggregateConfig *CoinPriceAggregateConfig
+	PriceHistoryConfig *PriceHistoryConfig
 	PriceNotifySlot int64
 	PriceNotifyConfig *PriceNotifyConfig
 	DBConfig              *DBConfig
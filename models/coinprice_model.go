@@ -17,11 +17,18 @@
 
 package models
 
+import "github.com/shopspring/decimal"
+
 type TokenBasic struct {
 	Name         string         `gorm:"primaryKey;size:64;not null"`
 	Price        int64          `gorm:"size:64;not null"`
 	PriceInd          uint64         `gorm:"type:bigint(20);not null"`
 	Time         int64          `gorm:"type:bigint(20);not null"`
+	Precision    uint64         `gorm:"type:bigint(20);not null"`
+	Property     int64          `gorm:"type:bigint(20);not null"`
+	Aggregation  string         `gorm:"size:32;not null"`
+	MinSources   int64          `gorm:"type:bigint(20);not null"`
+	MaxAge       int64          `gorm:"type:bigint(20);not null"`
 	PriceMarkets []*PriceMarket `gorm:"foreignKey:TokenBasicName;references:Name"`
 }
 
@@ -32,12 +39,45 @@ type PriceMarket struct {
 	Price          int64       `gorm:"type:bigint(20);not null"`
 	PriceInd            uint64      `gorm:"type:bigint(20);not null"`
 	Time           int64       `gorm:"type:bigint(20);not null"`
+	Volume         int64       `gorm:"type:bigint(20);not null"`
+	Weight         int64       `gorm:"type:bigint(20);not null;default:1"`
+	Priority       int64       `gorm:"type:bigint(20);not null;default:0"`
+	ExcludeReason  string      `gorm:"size:256;not null"`
 	TokenBasic     *TokenBasic `gorm:"foreignKey:TokenBasicName;references:Name"`
 }
 
+// CoinPrice is the price of a coin returned by a price market, it is not saved in db.
+// Volume is the 24h volume in the quote currency, 0 if the market does not provide it.
+type CoinPrice struct {
+	Price  decimal.Decimal
+	Volume decimal.Decimal
+	Time   int64
+}
+
+// PriceNotify is a notification rule of token, Type is one of NOTIFY_RULE_*:
+// cross: the price crosses the absolute level Price.
+// band: the price moves from the last notified price by Percent per-mille.
+// window: the price changes by Percent per-mille in the last Window seconds.
+// spread: the spread between the prices of two Markets separated by comma is larger than Percent per-mille.
+// A rule without Type is cross if Price is set, otherwise band. Direction is one of NOTIFY_DIRECTION_*.
+// A triggered cross, window or spread rule is armed again after the price goes back by Hysteresis per-mille, a band
+// rule moves its band with the price instead and does not accept Hysteresis. The notifications in Cooldown seconds
+// after a notification or while the rule is flapping are held back and collapsed. Severity is one of
+// NOTIFY_SEVERITY_*, info if it is not set, and it routes the notifications to the channels. Name identifies the
+// rule of token, so its state is kept when the rule is changed or seeded again, a rule without Name is identified by
+// its type and levels.
 type PriceNotify struct {
 	Id int64  `gorm:"primaryKey;autoIncrement"`
+	Name           string      `gorm:"size:64;not null"`
+	Type           string      `gorm:"size:32;not null"`
 	Price int64          `gorm:"size:64;not null"`
+	Percent        int64       `gorm:"type:bigint(20);not null"`
+	Direction      int64       `gorm:"type:bigint(20);not null"`
+	Window         int64       `gorm:"type:bigint(20);not null"`
+	Markets        string      `gorm:"size:256;not null"`
+	Hysteresis     int64       `gorm:"type:bigint(20);not null"`
+	Cooldown       int64       `gorm:"type:bigint(20);not null"`
+	Severity       string      `gorm:"size:32;not null"`
 	TokenBasicName string `gorm:"size:64;not null"`
 	TokenBasic     *TokenBasic `gorm:"foreignKey:TokenBasicName;references:Name"`
 }
@@ -0,0 +1,35 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+package models
+
+// PriceCandle is the open, high, low and close price of a token in a period starting at Time. A gap candle has no
+// price in the period, its prices are the close price of the candle before it and Count is 0. A partial candle is the
+// latest candle saved when the listener stops, it is loaded again when the listener starts and replaced when it is
+// closed.
+type PriceCandle struct {
+	Id             int64  `gorm:"primaryKey;autoIncrement"`
+	TokenBasicName string `gorm:"size:64;not null;uniqueIndex:idx_price_candle,priority:1"`
+	Period         int64  `gorm:"type:bigint(20);not null;uniqueIndex:idx_price_candle,priority:2"`
+	Time           int64  `gorm:"type:bigint(20);not null;uniqueIndex:idx_price_candle,priority:3"`
+	Open           int64  `gorm:"type:bigint(20);not null"`
+	High           int64  `gorm:"type:bigint(20);not null"`
+	Low            int64  `gorm:"type:bigint(20);not null"`
+	Close          int64  `gorm:"type:bigint(20);not null"`
+	Count          int64  `gorm:"type:bigint(20);not null"`
+	Gap            bool   `gorm:"not null"`
+	Partial        bool   `gorm:"not null"`
+}
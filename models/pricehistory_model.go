@@ -0,0 +1,30 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+package models
+
+// PriceHistory is a sample of token price. MarketName is empty for the aggregated price of token. Bucket is 0 for
+// the sample of an update, or the seconds of the bucket which the samples are rolled up into, and then Time is the
+// start of the bucket, Price is the average of the samples and Count is the number of samples.
+type PriceHistory struct {
+	Id             int64  `gorm:"primaryKey;autoIncrement"`
+	TokenBasicName string `gorm:"size:64;not null;index:idx_price_history_bucket_time,priority:3"`
+	MarketName     string `gorm:"size:64;not null;index:idx_price_history_bucket_time,priority:4"`
+	Bucket         int64  `gorm:"type:bigint(20);not null;index:idx_price_history_bucket_time,priority:1"`
+	Time           int64  `gorm:"type:bigint(20);not null;index:idx_price_history_bucket_time,priority:2"`
+	Price          int64  `gorm:"type:bigint(20);not null"`
+	Count          int64  `gorm:"type:bigint(20);not null"`
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+package models
+
+// PriceNotifyState is the state of a notification rule kept across restarts, RuleKey identifies the rule of token.
+// NotifyPrice, Ind and Time are the price, direction and time of the last notification sent. Base is the price
+// which the band of band rule is around, Side is the side of the level which the price of cross rule is on, and
+// Armed is false after window or spread rule is triggered until the price goes back. Pending is the latest
+// notification held back in json, PendingCount is the number of them and PendingTime is the time of the first one,
+// FlapTime is the start of the flap window and FlapCount is the number of triggers in it.
+type PriceNotifyState struct {
+	RuleKey        string `gorm:"primaryKey;size:128"`
+	TokenBasicName string `gorm:"size:64;not null"`
+	Type           string `gorm:"size:32;not null"`
+	NotifyPrice    int64  `gorm:"type:bigint(20);not null"`
+	Ind            int64  `gorm:"type:bigint(20);not null"`
+	Time           int64  `gorm:"type:bigint(20);not null"`
+	Base           int64  `gorm:"type:bigint(20);not null"`
+	Side           int64  `gorm:"type:bigint(20);not null"`
+	Armed          bool   `gorm:"not null"`
+	Pending        string `gorm:"type:text"`
+	PendingCount   int64  `gorm:"type:bigint(20);not null"`
+	PendingTime    int64  `gorm:"type:bigint(20);not null"`
+	FlapTime       int64  `gorm:"type:bigint(20);not null"`
+	FlapCount      int64  `gorm:"type:bigint(20);not null"`
+}
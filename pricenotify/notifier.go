@@ -0,0 +1,140 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+package pricenotify
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/astaxie/beego/logs"
+	"price_notify/basedef"
+	"price_notify/conf"
+	"price_notify/dingsdk"
+)
+
+// Notifier is a channel which the notifications are sent to
+type Notifier interface {
+	Notify(text string) error
+	Name() string
+}
+
+func NewNotifier(cfg *conf.NotifyChannelConfig) (Notifier, error) {
+	if cfg.Node == nil {
+		return nil, fmt.Errorf("Node of channel %s is not set", cfg.Name)
+	}
+	switch cfg.Type {
+	case basedef.NOTIFY_CHANNEL_DING:
+		return NewDingNotifier(cfg.Name, cfg.Switch, cfg.Node), nil
+	default:
+		return nil, fmt.Errorf("unknown type of channel %s: %s", cfg.Name, cfg.Type)
+	}
+}
+
+// DingNotifier sends the notifications as text messages of DingTalk robot, the messages are only logged if the
+// switch is off.
+type DingNotifier struct {
+	name    string
+	on      bool
+	dingSdk *dingsdk.DingSdk
+}
+
+func NewDingNotifier(name string, on bool, node *conf.Restful) *DingNotifier {
+	return &DingNotifier{
+		name:    name,
+		on:      on,
+		dingSdk: dingsdk.NewDingSdk(node.Url, node.Key),
+	}
+}
+
+func (notifier *DingNotifier) Notify(text string) error {
+	dingNotify := &dingsdk.DingNotify{
+		MsgType: "text",
+		Text: dingsdk.DingContent{
+			Content: text,
+		},
+		At: dingsdk.DingAt{
+			IsAtAll: false,
+		},
+	}
+	if notifier.on == false {
+		notifyJson, _ := json.Marshal(dingNotify)
+		logs.Info("ding notify of %s: %s", notifier.name, string(notifyJson))
+		return nil
+	}
+	result, err := notifier.dingSdk.Notify(dingNotify)
+	if err != nil {
+		return err
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("code: %d, err: %s", result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
+
+func (notifier *DingNotifier) Name() string {
+	return notifier.name
+}
+
+// channel routes the notifications of its tokens and severities to the notifier, a nil set matches all.
+type channel struct {
+	notifier   Notifier
+	tokens     map[string]bool
+	severities map[string]bool
+}
+
+func newChannel(notifier Notifier, tokens []string, severities []string) *channel {
+	c := &channel{notifier: notifier}
+	if len(tokens) > 0 {
+		c.tokens = make(map[string]bool, 0)
+		for _, token := range tokens {
+			c.tokens[token] = true
+		}
+	}
+	if len(severities) > 0 {
+		c.severities = make(map[string]bool, 0)
+		for _, severity := range severities {
+			c.severities[severity] = true
+		}
+	}
+	return c
+}
+
+func (c *channel) match(notify *Trigger) bool {
+	if c.tokens != nil && !c.tokens[notify.TokenName] {
+		return false
+	}
+	if c.severities != nil && !c.severities[notify.Severity] {
+		return false
+	}
+	return true
+}
+
+// newChannels creates the channels of config, and the fallback channel of the legacy Node if it is set
+func newChannels(cfg *conf.PriceNotifyConfig) ([]*channel, Notifier, error) {
+	channels := make([]*channel, 0)
+	for _, channelCfg := range cfg.Channels {
+		notifier, err := NewNotifier(channelCfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		channels = append(channels, newChannel(notifier, channelCfg.Tokens, channelCfg.Severities))
+	}
+	var fallback Notifier
+	if cfg.Node != nil {
+		fallback = NewDingNotifier("default", cfg.Switch, cfg.Node)
+	}
+	return channels, fallback, nil
+}
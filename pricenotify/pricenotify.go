@@ -18,12 +18,11 @@
 package pricenotify
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/astaxie/beego/logs"
 	"github.com/shopspring/decimal"
+	"price_notify/basedef"
 	"price_notify/conf"
-	"price_notify/dingsdk"
 	"price_notify/models"
 	"price_notify/pricenotifydao"
 	"runtime/debug"
@@ -47,35 +46,100 @@ func StopPriceNotify() {
 	}
 }
 
+var (
+	// per-mille band of the tokens without notification rules
+	DEFAULT_NOTIFY_PERCENT = int64(10)
+)
+
 type Trigger struct {
 	TokenName string
+	// one of NOTIFY_RULE_*
+	Type string
 	NotifyPrice int64
+	Precision uint64
 	Ind int64
+	// the level crossed by cross rule
+	Level int64
+	// the per-mille change of window rule, or the per-mille spread of spread rule
+	Percent int64
+	Window int64
+	Markets []string
+	Time int64
+	// the number of notifications collapsed in Window seconds if the rule is flapping
+	Flaps int64
+	// one of NOTIFY_SEVERITY_*
+	Severity string
+}
+
+// notifyRule is the rule built from the PriceNotify, it is rebuilt when the PriceNotify changes, and the state is
+// carried over if the type of rule is not changed.
+type notifyRule struct {
+	cfg models.PriceNotify
+	rule Rule
+	suppressor *Suppressor
+	// the price and direction of the last notification sent, its time is kept by suppressor
+	notifyPrice int64
+	ind int64
+}
+
+// state returns the state of rule and its last notification sent
+func (built *notifyRule) state(key string) *models.PriceNotifyState {
+	state := built.rule.State()
+	state.RuleKey = key
+	state.TokenBasicName = built.cfg.TokenBasicName
+	state.NotifyPrice, state.Ind = built.notifyPrice, built.ind
+	built.suppressor.State(state)
+	return state
+}
+
+// restore restores the state of rule and its last notification sent
+func (built *notifyRule) restore(state *models.PriceNotifyState) {
+	built.rule.Restore(state)
+	built.notifyPrice, built.ind = state.NotifyPrice, state.Ind
+	built.suppressor.Restore(state)
+}
+
+// sent records the notification sent at now
+func (built *notifyRule) sent(notify *Trigger, now int64) {
+	built.notifyPrice, built.ind = notify.NotifyPrice, notify.Ind
+	built.suppressor.Sent(now)
 }
 
 type PriceNotify struct {
 	priceNotifySlot int64
 	cfg *conf.PriceNotifyConfig
 	exit            chan bool
-	notifies map[string]*Trigger
+	rules           map[string]*notifyRule
+	states          map[string]*models.PriceNotifyState
 	db              pricenotifydao.PriceNotifyDao
-	dingSdk         *dingsdk.DingSdk
+	channels        []*channel
+	fallback        Notifier
 }
 
 func NewPriceNotify(priceNotifySlot int64, priceNotifyCfg *conf.PriceNotifyConfig, db pricenotifydao.PriceNotifyDao) *PriceNotify {
 	priceNotify := &PriceNotify{}
 	priceNotify.priceNotifySlot = priceNotifySlot
 	priceNotify.cfg = priceNotifyCfg
-	priceNotify.notifies = make(map[string]*Trigger, 0)
+	priceNotify.rules = make(map[string]*notifyRule, 0)
+	priceNotify.states = make(map[string]*models.PriceNotifyState, 0)
 	priceNotify.db = db
 	priceNotify.exit = make(chan bool, 0)
-	priceNotify.dingSdk = dingsdk.NewDingSdk(priceNotifyCfg.Node.Url, priceNotifyCfg.Node.Key)
-	//
-	tokens, err := db.GetTokens()
+	channels, fallback, err := newChannels(priceNotifyCfg)
 	if err != nil {
 		panic(err)
 	}
-	err = priceNotify.checkNotifies(tokens)
+	priceNotify.channels = channels
+	priceNotify.fallback = fallback
+	// restore the states of rules, so the prices notified before restart are not notified again
+	states, err := db.GetNotifyStates()
+	if err != nil {
+		panic(err)
+	}
+	for _, state := range states {
+		priceNotify.states[state.RuleKey] = state
+	}
+	//
+	err = priceNotify.CheckNotifies()
 	if err != nil {
 		panic(err)
 	}
@@ -117,14 +181,9 @@ func (cpl *PriceNotify) priceNotify() (exit bool) {
 		select {
 		case <-ticker.C:
 			logs.Info("do price notify at time: %s", time.Now().Format("2006-01-02 15:04:05"))
-			tokens, err := cpl.db.GetTokens()
-			if err != nil {
-				logs.Error("get price notify err: %v", err)
-				continue
-			}
-			err = cpl.checkNotifies(tokens)
+			err := cpl.CheckNotifies()
 			if err != nil {
-				logs.Error("check price notify err: %v", err)
+				logs.Error("%v", err)
 				continue
 			}
 			break
@@ -135,74 +194,185 @@ func (cpl *PriceNotify) priceNotify() (exit bool) {
 	}
 }
 
-func (cpl *PriceNotify) checkNotifies(tokens []*models.TokenBasic) error {
-	newNotifies := make([]*Trigger, 0)
+// CheckNotifies reloads the tokens and the notification rules, and notifies the prices which match the rules.
+func (cpl *PriceNotify) CheckNotifies() error {
+	tokens, err := cpl.db.GetTokens()
+	if err != nil {
+		return fmt.Errorf("get tokens err: %v", err)
+	}
+	rules, err := cpl.db.GetNotifies()
+	if err != nil {
+		return fmt.Errorf("get price notify err: %v", err)
+	}
+	err = cpl.checkNotifies(tokens, rules)
+	if err != nil {
+		return fmt.Errorf("check price notify err: %v", err)
+	}
+	return nil
+}
+
+// checkNotifies checks the prices of tokens against their rules, a token without rules is notified when its price
+// moves by DEFAULT_NOTIFY_PERCENT per-mille.
+func (cpl *PriceNotify) checkNotifies(tokens []*models.TokenBasic, rules []*models.PriceNotify) error {
+	tokenRules := make(map[string][]*models.PriceNotify, 0)
+	for _, rule := range rules {
+		tokenRules[rule.TokenBasicName] = append(tokenRules[rule.TokenBasicName], rule)
+	}
+	for _, token := range tokens {
+		if _, ok := tokenRules[token.Name]; !ok {
+			tokenRules[token.Name] = []*models.PriceNotify{{TokenBasicName: token.Name, Percent: DEFAULT_NOTIFY_PERCENT}}
+		}
+	}
+	now := time.Now().Unix()
+	newRules := make(map[string]*notifyRule, 0)
 	for _, token := range tokens {
-		notify, ok := cpl.notifies[token.Name]
-		if !ok {
-			notify = &Trigger{
-				TokenName:   token.Name,
-				NotifyPrice: token.Price,
-				Ind:         1,
+		if token.Property == basedef.TOKEN_PROPERTY_DISABLED || token.Price <= 0 {
+			continue
+		}
+		for _, rule := range tokenRules[token.Name] {
+			key := ruleKey(rule)
+			if _, ok := newRules[key]; ok {
+				logs.Error("price notify rule %s is duplicated", key)
+				continue
 			}
-			newNotifies = append(newNotifies, notify)
-			cpl.notifies[token.Name] = notify
-		} else {
-			percent, ind := cpl.pricePercent(token.Price, notify.NotifyPrice)
-			if percent > 10 {
-				notify.NotifyPrice = token.Price
-				notify.Ind = ind
-				newNotifies = append(newNotifies, notify)
+			cfg := *rule
+			cfg.Id = 0
+			cfg.TokenBasic = nil
+			built, ok := cpl.rules[key]
+			if !ok || built.cfg != cfg {
+				newRule, err := NewRule(rule)
+				if err != nil {
+					logs.Error("price notify rule %s is invalid: %v", key, err)
+					continue
+				}
+				state, hasState := cpl.states[key]
+				if ok {
+					state, hasState = built.state(key), true
+				}
+				built = &notifyRule{cfg: cfg, rule: newRule, suppressor: NewSuppressor(rule.Cooldown,
+					cpl.cfg.FlapNotifies, cpl.cfg.FlapTriggers, cpl.cfg.FlapWindow)}
+				if hasState && state.Type == RuleType(rule) {
+					built.restore(state)
+				}
 			}
+			newRules[key] = built
+			notify := built.suppressor.Filter(built.rule.Check(token, now), now)
+			if notify == nil {
+				continue
+			}
+			notify.Severity = RuleSeverity(rule)
+			if cpl.notify(notify) {
+				built.sent(notify, now)
+			}
+		}
+	}
+	cpl.rules = newRules
+	return cpl.saveStates(tokenRules)
+}
+
+// saveStates saves the states of rules which are changed since the last save, and deletes the states of the rules
+// which are removed
+func (cpl *PriceNotify) saveStates(tokenRules map[string][]*models.PriceNotify) error {
+	newStates := make([]*models.PriceNotifyState, 0)
+	for key, built := range cpl.rules {
+		state := built.state(key)
+		oldState, ok := cpl.states[key]
+		if ok && *oldState == *state {
+			continue
+		}
+		newStates = append(newStates, state)
+	}
+	err := cpl.db.SaveNotifyStates(newStates)
+	if err != nil {
+		return fmt.Errorf("save notify states err: %v", err)
+	}
+	for _, state := range newStates {
+		cpl.states[state.RuleKey] = state
+	}
+	keys := make(map[string]bool, 0)
+	for _, rules := range tokenRules {
+		for _, rule := range rules {
+			keys[ruleKey(rule)] = true
+		}
+	}
+	removedKeys := make([]string, 0)
+	for key := range cpl.states {
+		if !keys[key] {
+			removedKeys = append(removedKeys, key)
 		}
 	}
-	for _, notify := range newNotifies {
-		cpl.notify(notify)
+	err = cpl.db.DeleteNotifyStates(removedKeys)
+	if err != nil {
+		return fmt.Errorf("delete notify states err: %v", err)
+	}
+	for _, key := range removedKeys {
+		delete(cpl.states, key)
 	}
 	return nil
 }
 
-func (cpl *PriceNotify) pricePercent(price int64, base int64) (int64, int64) {
-	ind := int64(0)
-	diff := price - base
-	if diff < 0 {
-		ind = -1
-		diff = 0 - diff
-	} else {
-		ind = 1
+// ruleKey identifies the rule of token by its Name, or by its type and levels if it has no Name, so the state of
+// rule is kept when the rules are seeded again with new ids.
+func ruleKey(rule *models.PriceNotify) string {
+	if rule.Name != "" {
+		return fmt.Sprintf("%s#%s", rule.TokenBasicName, rule.Name)
 	}
-	percent := diff * 1000 / base
-	return percent, ind
+	return fmt.Sprintf("%s#%s:%d:%d:%d:%d:%s", rule.TokenBasicName, RuleType(rule), rule.Price, rule.Percent,
+		rule.Direction, rule.Window, rule.Markets)
 }
 
-func (cpl *PriceNotify) notify(notify *Trigger) error {
+// notifyText renders the text message of notification
+func notifyText(notify *Trigger) string {
 	tag := "up"
 	if notify.Ind == -1 {
 		tag = "down"
 	}
 	price := decimal.NewFromInt(notify.NotifyPrice)
-	newPrice := price.Div(decimal.NewFromInt(100000000))
-	dingText := fmt.Sprintf("%s price is %s to %s", notify.TokenName, tag, newPrice.String())
-	dingNotify := &dingsdk.DingNotify{
-		MsgType: "text",
-		Text:    dingsdk.DingContent{
-			Content : dingText,
-		},
-		At:      dingsdk.DingAt{
-			IsAtAll: false,
-		},
-	}
-	if cpl.cfg.Switch == false {
-		notifyJson, _ := json.Marshal(dingNotify)
-		logs.Info("ding notify: %s", string(notifyJson))
-		return nil
-	}
-	result, err := cpl.dingSdk.Notify(dingNotify)
-	if err != nil {
-		return err
+	newPrice := price.Shift(-basedef.TokenDecimals(notify.Precision))
+	percent := decimal.New(notify.Percent, -1)
+	var text string
+	switch {
+	case notify.Flaps > 0:
+		text = fmt.Sprintf("%s price is flapping, %d notifications in %ds, last %s to %s", notify.TokenName, notify.Flaps, notify.Window, tag, newPrice.String())
+	case notify.Type == basedef.NOTIFY_RULE_WINDOW:
+		text = fmt.Sprintf("%s price is %s %s%% in %ds to %s", notify.TokenName, tag, percent.String(), notify.Window, newPrice.String())
+	case notify.Type == basedef.NOTIFY_RULE_SPREAD:
+		text = fmt.Sprintf("%s spread between %s and %s is %s%%, price is %s", notify.TokenName, notify.Markets[0], notify.Markets[1], percent.String(), newPrice.String())
+	default:
+		text = fmt.Sprintf("%s price is %s to %s", notify.TokenName, tag, newPrice.String())
+		if notify.Level > 0 {
+			level := decimal.NewFromInt(notify.Level).Shift(-basedef.TokenDecimals(notify.Precision))
+			text += fmt.Sprintf(", crossing %s", level.String())
+		}
 	}
-	if result.ErrCode != 0 {
-		return fmt.Errorf("code: %d, err: %s", result.ErrCode, result.ErrMsg)
+	return text
+}
+
+// notify sends the notification to the channels routed, or to the fallback channel if it is not routed. It returns
+// false if the notification is not sent to any of the channels, the errors of channels are logged.
+func (cpl *PriceNotify) notify(notify *Trigger) bool {
+	text := notifyText(notify)
+	notifiers := make([]Notifier, 0)
+	for _, channel := range cpl.channels {
+		if channel.match(notify) {
+			notifiers = append(notifiers, channel.notifier)
+		}
 	}
-	return nil
+	if len(notifiers) == 0 && cpl.fallback != nil {
+		notifiers = append(notifiers, cpl.fallback)
+	}
+	if len(notifiers) == 0 {
+		logs.Warn("no channel for notification: %s", text)
+		return false
+	}
+	sent := false
+	for _, notifier := range notifiers {
+		err := notifier.Notify(text)
+		if err != nil {
+			logs.Error("notify price of token %s to channel %s err: %v", notify.TokenName, notifier.Name(), err)
+			continue
+		}
+		sent = true
+	}
+	return sent
 }
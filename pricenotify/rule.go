@@ -0,0 +1,333 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+package pricenotify
+
+import (
+	"fmt"
+	"price_notify/basedef"
+	"price_notify/models"
+	"strings"
+)
+
+// Rule is the state machine of a notification rule, it is checked with the prices of token one by one.
+type Rule interface {
+	// Check returns the trigger if the price of token at now matches the rule, nil otherwise
+	Check(token *models.TokenBasic, now int64) *Trigger
+	// State returns the state of rule to keep across restarts, the notification sent is not a part of it
+	State() *models.PriceNotifyState
+	// Restore restores the state of rule kept before restart
+	Restore(state *models.PriceNotifyState)
+}
+
+// NewRule creates the rule of PriceNotify by its type
+func NewRule(rule *models.PriceNotify) (Rule, error) {
+	if rule.Hysteresis < 0 || rule.Cooldown < 0 {
+		return nil, fmt.Errorf("Hysteresis or Cooldown is negative")
+	}
+	switch RuleSeverity(rule) {
+	case basedef.NOTIFY_SEVERITY_INFO, basedef.NOTIFY_SEVERITY_WARNING, basedef.NOTIFY_SEVERITY_CRITICAL:
+	default:
+		return nil, fmt.Errorf("unknown severity: %s", rule.Severity)
+	}
+	switch RuleType(rule) {
+	case basedef.NOTIFY_RULE_CROSS:
+		if rule.Price <= 0 {
+			return nil, fmt.Errorf("Price of cross rule is not set")
+		}
+		return NewCrossRule(rule.Price, rule.Hysteresis, rule.Direction), nil
+	case basedef.NOTIFY_RULE_BAND:
+		if rule.Percent <= 0 {
+			return nil, fmt.Errorf("Percent of band rule is not set")
+		}
+		if rule.Hysteresis != 0 {
+			return nil, fmt.Errorf("Hysteresis is not supported by band rule")
+		}
+		return &BandRule{percent: rule.Percent, direction: rule.Direction}, nil
+	case basedef.NOTIFY_RULE_WINDOW:
+		if rule.Percent <= 0 || rule.Window <= 0 {
+			return nil, fmt.Errorf("Percent or Window of window rule is not set")
+		}
+		if rule.Hysteresis >= rule.Percent {
+			return nil, fmt.Errorf("Hysteresis of window rule is not less than Percent")
+		}
+		return &WindowRule{percent: rule.Percent, hysteresis: rule.Hysteresis, window: rule.Window, direction: rule.Direction, armed: true}, nil
+	case basedef.NOTIFY_RULE_SPREAD:
+		markets := strings.Split(rule.Markets, ",")
+		if rule.Percent <= 0 || len(markets) != 2 {
+			return nil, fmt.Errorf("Percent or Markets of spread rule is not set")
+		}
+		if rule.Hysteresis >= rule.Percent {
+			return nil, fmt.Errorf("Hysteresis of spread rule is not less than Percent")
+		}
+		for i := range markets {
+			markets[i] = strings.TrimSpace(markets[i])
+		}
+		return &SpreadRule{percent: rule.Percent, hysteresis: rule.Hysteresis, markets: markets, direction: rule.Direction, armed: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown rule type: %s", rule.Type)
+	}
+}
+
+// RuleType returns the type of rule, it is cross if Price is set, otherwise band if there is no Type.
+func RuleType(rule *models.PriceNotify) string {
+	if rule.Type != "" {
+		return rule.Type
+	}
+	if rule.Price > 0 {
+		return basedef.NOTIFY_RULE_CROSS
+	}
+	return basedef.NOTIFY_RULE_BAND
+}
+
+func matchDirection(direction int64, ind int64) bool {
+	return direction == basedef.NOTIFY_DIRECTION_BOTH || direction == ind
+}
+
+// pricePercent returns the per-mille change from base to price and its direction
+func pricePercent(price int64, base int64) (int64, int64) {
+	ind := int64(0)
+	diff := price - base
+	if diff < 0 {
+		ind = -1
+		diff = 0 - diff
+	} else {
+		ind = 1
+	}
+	percent := diff * 1000 / base
+	return percent, ind
+}
+
+func newTrigger(ruleType string, token *models.TokenBasic, ind int64, now int64) *Trigger {
+	return &Trigger{
+		TokenName:   token.Name,
+		Type:        ruleType,
+		NotifyPrice: token.Price,
+		Precision:   token.Precision,
+		Ind:         ind,
+		Time:        now,
+	}
+}
+
+// CrossRule tracks the side of the level which the price is on, it is triggered when the price moves to the other
+// side, and then it is armed for the crossing back. With hysteresis, the price has to move beyond the level by
+// hysteresis per-mille in the direction not notified before it is on the other side, so a rule notifying the
+// upward crossing at the level is armed again after the price falls below the lower edge of the band.
+type CrossRule struct {
+	level     int64
+	direction int64
+	// the price is on the upper side at or above up, and on the lower side below down
+	up   int64
+	down int64
+	// 1 if the price is on the upper side, -1 if on the lower side, 0 before the first price
+	side int64
+}
+
+func NewCrossRule(level int64, hysteresis int64, direction int64) *CrossRule {
+	band := level * hysteresis / 1000
+	rule := &CrossRule{level: level, direction: direction, up: level, down: level}
+	if direction != basedef.NOTIFY_DIRECTION_DOWN {
+		rule.down -= band
+	}
+	if direction != basedef.NOTIFY_DIRECTION_UP {
+		rule.up += band
+	}
+	return rule
+}
+
+func (rule *CrossRule) Check(token *models.TokenBasic, now int64) *Trigger {
+	if rule.side == 0 {
+		rule.side = basedef.NOTIFY_DIRECTION_UP
+		if token.Price < rule.level {
+			rule.side = basedef.NOTIFY_DIRECTION_DOWN
+		}
+		return nil
+	}
+	side := rule.side
+	if token.Price >= rule.up {
+		side = basedef.NOTIFY_DIRECTION_UP
+	} else if token.Price < rule.down {
+		side = basedef.NOTIFY_DIRECTION_DOWN
+	}
+	if rule.side == side {
+		return nil
+	}
+	rule.side = side
+	if !matchDirection(rule.direction, side) {
+		return nil
+	}
+	trigger := newTrigger(basedef.NOTIFY_RULE_CROSS, token, side, now)
+	trigger.Level = rule.level
+	return trigger
+}
+
+func (rule *CrossRule) State() *models.PriceNotifyState {
+	return &models.PriceNotifyState{Type: basedef.NOTIFY_RULE_CROSS, Side: rule.side}
+}
+
+func (rule *CrossRule) Restore(state *models.PriceNotifyState) {
+	rule.side = state.Side
+}
+
+// BandRule keeps a band around the last notified price, it is triggered when the price moves out of the band, and
+// then the band moves to the price. The band is set silently around the price seen first, and it moves silently if
+// the price moves out of it in the other direction. It is not armed again by hysteresis, as the band always moves
+// with the price.
+type BandRule struct {
+	percent   int64
+	direction int64
+	// the last notified price, 0 before the first price
+	base int64
+}
+
+func (rule *BandRule) Check(token *models.TokenBasic, now int64) *Trigger {
+	if rule.base == 0 {
+		rule.base = token.Price
+		return nil
+	}
+	percent, ind := pricePercent(token.Price, rule.base)
+	if percent <= rule.percent {
+		return nil
+	}
+	rule.base = token.Price
+	if !matchDirection(rule.direction, ind) {
+		return nil
+	}
+	return newTrigger(basedef.NOTIFY_RULE_BAND, token, ind, now)
+}
+
+func (rule *BandRule) State() *models.PriceNotifyState {
+	return &models.PriceNotifyState{Type: basedef.NOTIFY_RULE_BAND, Base: rule.base}
+}
+
+func (rule *BandRule) Restore(state *models.PriceNotifyState) {
+	rule.base = state.Base
+}
+
+type priceSample struct {
+	time  int64
+	price int64
+}
+
+// armed returns whether the armed rule is triggered by the per-mille value which is larger than percent, the rule
+// is armed again after the value goes back to percent less hysteresis, or it moves in the direction not matched.
+func armed(armed *bool, value int64, matched bool, percent int64, hysteresis int64) bool {
+	if value > percent && matched {
+		triggered := *armed
+		*armed = false
+		return triggered
+	}
+	if value <= percent-hysteresis || !matched {
+		*armed = true
+	}
+	return false
+}
+
+// WindowRule keeps the prices in the last window seconds, it is triggered when the change from the oldest price in
+// the window is larger than percent, and it is armed again after the change goes back by hysteresis.
+type WindowRule struct {
+	percent    int64
+	hysteresis int64
+	window     int64
+	direction  int64
+	samples    []*priceSample
+	armed      bool
+}
+
+func (rule *WindowRule) Check(token *models.TokenBasic, now int64) *Trigger {
+	rule.samples = append(rule.samples, &priceSample{time: now, price: token.Price})
+	first := 0
+	for first < len(rule.samples)-1 && rule.samples[first].time < now-rule.window {
+		first++
+	}
+	rule.samples = rule.samples[first:]
+	percent, ind := pricePercent(token.Price, rule.samples[0].price)
+	if !armed(&rule.armed, percent, matchDirection(rule.direction, ind), rule.percent, rule.hysteresis) {
+		return nil
+	}
+	trigger := newTrigger(basedef.NOTIFY_RULE_WINDOW, token, ind, now)
+	trigger.Percent = percent
+	trigger.Window = rule.window
+	return trigger
+}
+
+// State of window rule does not keep the prices in the window, the window starts again after restart.
+func (rule *WindowRule) State() *models.PriceNotifyState {
+	return &models.PriceNotifyState{Type: basedef.NOTIFY_RULE_WINDOW, Armed: rule.armed}
+}
+
+func (rule *WindowRule) Restore(state *models.PriceNotifyState) {
+	rule.armed = state.Armed
+}
+
+// SpreadRule compares the prices of token in two markets, it is triggered when the spread is larger than percent,
+// and it is armed again after the spread goes back by hysteresis. The direction is up if the price of the first
+// market is higher.
+type SpreadRule struct {
+	percent    int64
+	hysteresis int64
+	markets    []string
+	direction  int64
+	armed      bool
+}
+
+func marketPrice(token *models.TokenBasic, market string) int64 {
+	for _, tokenPrice := range token.PriceMarkets {
+		if tokenPrice.MarketName == market && tokenPrice.PriceInd == basedef.PRICE_IND_FRESH {
+			return tokenPrice.Price
+		}
+	}
+	return 0
+}
+
+func (rule *SpreadRule) Check(token *models.TokenBasic, now int64) *Trigger {
+	price0, price1 := marketPrice(token, rule.markets[0]), marketPrice(token, rule.markets[1])
+	if price0 <= 0 || price1 <= 0 {
+		return nil
+	}
+	base := price0
+	if price1 < base {
+		base = price1
+	}
+	percent, _ := pricePercent(price0-price1+base, base)
+	ind := basedef.NOTIFY_DIRECTION_UP
+	if price0 < price1 {
+		ind = basedef.NOTIFY_DIRECTION_DOWN
+	}
+	if !armed(&rule.armed, percent, matchDirection(rule.direction, ind), rule.percent, rule.hysteresis) {
+		return nil
+	}
+	trigger := newTrigger(basedef.NOTIFY_RULE_SPREAD, token, ind, now)
+	trigger.Percent = percent
+	trigger.Markets = rule.markets
+	return trigger
+}
+
+func (rule *SpreadRule) State() *models.PriceNotifyState {
+	return &models.PriceNotifyState{Type: basedef.NOTIFY_RULE_SPREAD, Armed: rule.armed}
+}
+
+func (rule *SpreadRule) Restore(state *models.PriceNotifyState) {
+	rule.armed = state.Armed
+}
+
+// RuleSeverity returns the severity of rule, it is info if there is no Severity
+func RuleSeverity(rule *models.PriceNotify) string {
+	if rule.Severity == "" {
+		return basedef.NOTIFY_SEVERITY_INFO
+	}
+	return rule.Severity
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright (C) 2020 The poly network Authors
+ * This file is part of The poly network library.
+ *
+ * The  poly network  is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The  poly network  is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The poly network .  If not, see <http://www.gnu.org/licenses/>.
+ */
+package pricenotify
+
+import (
+	"encoding/json"
+	"github.com/astaxie/beego/logs"
+	"price_notify/models"
+)
+
+var (
+	// the number of notifications held back which are collapsed into a flapping notification
+	DEFAULT_FLAP_NOTIFIES = int64(2)
+	// a rule triggered DEFAULT_FLAP_TRIGGERS times in DEFAULT_FLAP_WINDOW seconds is flapping until the window ends
+	DEFAULT_FLAP_TRIGGERS = int64(3)
+	DEFAULT_FLAP_WINDOW   = int64(3600)
+)
+
+// Suppressor holds back the notifications of a rule in the cooldown after a notification is sent, and while the
+// rule is flapping. When they end, a single notification held back is sent as it is, and the notifications of a
+// rule which toggles again and again are collapsed into one flapping notification with their count.
+type Suppressor struct {
+	cooldown     int64
+	flapNotifies int64
+	flapTriggers int64
+	flapWindow   int64
+	// the time of the last notification sent
+	last int64
+	// the latest notification held back, the number of them and the time of the first one
+	pending *Trigger
+	count   int64
+	since   int64
+	// the start of the flap window and the number of triggers in it
+	flapTime  int64
+	flapCount int64
+}
+
+// NewSuppressor creates the suppressor of a rule, a rule triggered flapTriggers times in flapWindow seconds is
+// flapping, and flapNotifies notifications held back are collapsed. The defaults are used for the values not set.
+func NewSuppressor(cooldown int64, flapNotifies int64, flapTriggers int64, flapWindow int64) *Suppressor {
+	if flapNotifies <= 0 {
+		flapNotifies = DEFAULT_FLAP_NOTIFIES
+	}
+	if flapTriggers <= 0 {
+		flapTriggers = DEFAULT_FLAP_TRIGGERS
+	}
+	if flapWindow <= 0 {
+		flapWindow = DEFAULT_FLAP_WINDOW
+	}
+	return &Suppressor{cooldown: cooldown, flapNotifies: flapNotifies, flapTriggers: flapTriggers, flapWindow: flapWindow}
+}
+
+// Filter returns the notification to send at now, the trigger of rule is nil if the rule is not triggered. The
+// notification is held back until it is reported by Sent.
+func (s *Suppressor) Filter(trigger *Trigger, now int64) *Trigger {
+	if now-s.flapTime >= s.flapWindow {
+		s.flapTime, s.flapCount = now, 0
+	}
+	if trigger != nil {
+		if s.pending == nil {
+			s.since = now
+		}
+		s.pending = trigger
+		s.count++
+		s.flapCount++
+	}
+	if s.pending == nil || s.flapCount >= s.flapTriggers || (s.last > 0 && now < s.last+s.cooldown) {
+		return nil
+	}
+	if s.count < s.flapNotifies {
+		return s.pending
+	}
+	flap := *s.pending
+	flap.Flaps = s.count
+	flap.Window = now - s.since
+	return &flap
+}
+
+// Sent reports the notification returned by Filter is sent at now
+func (s *Suppressor) Sent(now int64) {
+	s.pending, s.count, s.since = nil, 0, 0
+	s.last = now
+}
+
+// State saves the state of suppressor to the state of rule
+func (s *Suppressor) State(state *models.PriceNotifyState) {
+	state.Time = s.last
+	state.Pending = ""
+	if s.pending != nil {
+		pending, _ := json.Marshal(s.pending)
+		state.Pending = string(pending)
+	}
+	state.PendingCount, state.PendingTime = s.count, s.since
+	state.FlapTime, state.FlapCount = s.flapTime, s.flapCount
+}
+
+// Restore restores the state of suppressor from the state of rule
+func (s *Suppressor) Restore(state *models.PriceNotifyState) {
+	s.last = state.Time
+	s.pending = nil
+	if state.Pending != "" {
+		pending := new(Trigger)
+		err := json.Unmarshal([]byte(state.Pending), pending)
+		if err != nil {
+			logs.Error("restore notification held back of %s err: %v", state.RuleKey, err)
+		} else {
+			s.pending = pending
+		}
+	}
+	s.count, s.since = state.PendingCount, state.PendingTime
+	s.flapTime, s.flapCount = state.FlapTime, state.FlapCount
+}
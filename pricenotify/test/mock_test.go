@@ -0,0 +1,110 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"price_notify/basedef"
+	"price_notify/conf"
+	"price_notify/dingsdk"
+	"price_notify/models"
+	"sync"
+	"testing"
+)
+
+// mockPriceNotifyDao keeps the tokens, rules and states in memory
+type mockPriceNotifyDao struct {
+	tokens []*models.TokenBasic
+	rules  []*models.PriceNotify
+	states map[string]*models.PriceNotifyState
+}
+
+func (dao *mockPriceNotifyDao) AddNotifies(rules []*models.PriceNotify) error {
+	dao.rules = append(dao.rules, rules...)
+	return nil
+}
+
+func (dao *mockPriceNotifyDao) GetNotifies() ([]*models.PriceNotify, error) {
+	return dao.rules, nil
+}
+
+func (dao *mockPriceNotifyDao) GetTokens() ([]*models.TokenBasic, error) {
+	return dao.tokens, nil
+}
+
+func (dao *mockPriceNotifyDao) GetNotifyStates() ([]*models.PriceNotifyState, error) {
+	states := make([]*models.PriceNotifyState, 0)
+	for _, state := range dao.states {
+		stateCopy := *state
+		states = append(states, &stateCopy)
+	}
+	return states, nil
+}
+
+func (dao *mockPriceNotifyDao) SaveNotifyStates(states []*models.PriceNotifyState) error {
+	if dao.states == nil {
+		dao.states = make(map[string]*models.PriceNotifyState, 0)
+	}
+	for _, state := range states {
+		stateCopy := *state
+		dao.states[state.RuleKey] = &stateCopy
+	}
+	return nil
+}
+
+func (dao *mockPriceNotifyDao) DeleteNotifyStates(ruleKeys []string) error {
+	for _, ruleKey := range ruleKeys {
+		delete(dao.states, ruleKey)
+	}
+	return nil
+}
+
+func (dao *mockPriceNotifyDao) Name() string {
+	return "mock"
+}
+
+// dingServer is a stand-in of DingTalk robot which records the messages
+type dingServer struct {
+	server   *httptest.Server
+	messages []string
+	lock     sync.Mutex
+}
+
+func newDingServer(t *testing.T) *dingServer {
+	ding := &dingServer{}
+	ding.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notify := new(dingsdk.DingNotify)
+		if err := json.NewDecoder(r.Body).Decode(notify); err != nil {
+			t.Errorf("decode ding notify err: %v", err)
+		}
+		ding.lock.Lock()
+		ding.messages = append(ding.messages, notify.Text.Content)
+		ding.lock.Unlock()
+		w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	return ding
+}
+
+func (ding *dingServer) config() *conf.PriceNotifyConfig {
+	return &conf.PriceNotifyConfig{Switch: true, Node: &conf.Restful{Url: ding.server.URL + "/", Key: "key"}}
+}
+
+// take returns the messages received and clears them
+func (ding *dingServer) take() []string {
+	ding.lock.Lock()
+	defer ding.lock.Unlock()
+	messages := ding.messages
+	ding.messages = nil
+	return messages
+}
+
+func (ding *dingServer) channel(name string, tokens []string, severities []string) *conf.NotifyChannelConfig {
+	return &conf.NotifyChannelConfig{
+		Name:       name,
+		Type:       basedef.NOTIFY_CHANNEL_DING,
+		Switch:     true,
+		Node:       &conf.Restful{Url: ding.server.URL + "/", Key: name},
+		Tokens:     tokens,
+		Severities: severities,
+	}
+}
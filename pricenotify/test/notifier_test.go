@@ -0,0 +1,64 @@
+package test
+
+import (
+	"price_notify/basedef"
+	"price_notify/conf"
+	"price_notify/models"
+	"price_notify/pricenotify"
+	"reflect"
+	"testing"
+)
+
+func TestPriceNotifyChannels(t *testing.T) {
+	fallback, ops, trading := newDingServer(t), newDingServer(t), newDingServer(t)
+	defer fallback.server.Close()
+	defer ops.server.Close()
+	defer trading.server.Close()
+	cfg := fallback.config()
+	cfg.Channels = []*conf.NotifyChannelConfig{
+		ops.channel("ops", nil, []string{basedef.NOTIFY_SEVERITY_CRITICAL}),
+		trading.channel("trading", []string{"BTC", "ETH"}, nil),
+	}
+	btc := &models.TokenBasic{Name: "BTC", Price: 4950000000000}
+	eth := &models.TokenBasic{Name: "ETH", Price: 60000000000}
+	dot := &models.TokenBasic{Name: "DOT", Price: 2000000000}
+	dao := &mockPriceNotifyDao{
+		tokens: []*models.TokenBasic{btc, eth, dot},
+		rules: []*models.PriceNotify{
+			{Id: 1, TokenBasicName: "BTC", Price: 5000000000000, Severity: basedef.NOTIFY_SEVERITY_CRITICAL},
+			{Id: 2, TokenBasicName: "ETH", Percent: 50, Severity: basedef.NOTIFY_SEVERITY_WARNING},
+			{Id: 3, TokenBasicName: "DOT", Percent: 50},
+		},
+	}
+	priceNotify := pricenotify.NewPriceNotify(1, cfg, dao)
+	btc.Price, eth.Price, dot.Price = 5010000000000, 63100000000, 2200000000
+	if err := priceNotify.CheckNotifies(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []struct {
+		ding     *dingServer
+		messages []string
+	}{
+		{ops, []string{"BTC price is up to 50100, crossing 50000"}},
+		{trading, []string{"BTC price is up to 50100, crossing 50000", "ETH price is up to 631"}},
+		// the notifications not routed to any channel are sent to the fallback channel
+		{fallback, []string{"DOT price is up to 22"}},
+	}
+	for i, channel := range expected {
+		if messages := channel.ding.take(); !reflect.DeepEqual(messages, channel.messages) {
+			t.Errorf("channel %d: expected %v, got %v", i, channel.messages, messages)
+		}
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	if _, err := pricenotify.NewNotifier(&conf.NotifyChannelConfig{Name: "ops", Type: "mail", Node: &conf.Restful{}}); err == nil {
+		t.Errorf("expected err of unknown channel type")
+	}
+	if _, err := pricenotify.NewNotifier(&conf.NotifyChannelConfig{Name: "ops", Type: basedef.NOTIFY_CHANNEL_DING}); err == nil {
+		t.Errorf("expected err of channel without node")
+	}
+	if _, err := pricenotify.NewRule(&models.PriceNotify{Price: 100, Severity: "fatal"}); err == nil {
+		t.Errorf("expected err of unknown severity")
+	}
+}
@@ -0,0 +1,130 @@
+package test
+
+import (
+	"price_notify/basedef"
+	"price_notify/models"
+	"price_notify/pricenotify"
+	"reflect"
+	"testing"
+)
+
+func TestPriceNotifyRules(t *testing.T) {
+	ding := newDingServer(t)
+	defer ding.server.Close()
+	btc := &models.TokenBasic{Name: "BTC", Price: 4950000000000}
+	eth := &models.TokenBasic{Name: "ETH", Price: 60000000000}
+	dao := &mockPriceNotifyDao{
+		tokens: []*models.TokenBasic{btc, eth},
+		rules: []*models.PriceNotify{
+			{Id: 1, TokenBasicName: "BTC", Price: 5000000000000},
+			{Id: 2, TokenBasicName: "BTC", Price: 4900000000000, Direction: basedef.NOTIFY_DIRECTION_DOWN},
+		},
+	}
+	priceNotify := pricenotify.NewPriceNotify(1, ding.config(), dao)
+	// the band of the token without rules is set silently when it is seen first
+	if messages := ding.take(); len(messages) != 0 {
+		t.Errorf("unexpected messages: %v", messages)
+	}
+	steps := []struct {
+		btc      int64
+		eth      int64
+		messages []string
+	}{
+		{5010000000000, 60500000000, []string{"BTC price is up to 50100, crossing 50000"}},
+		{4890000000000, 60700000000, []string{"BTC price is down to 48900, crossing 50000", "BTC price is down to 48900, crossing 49000", "ETH price is up to 607"}},
+		{4950000000000, 60700000000, nil},
+	}
+	for i, step := range steps {
+		btc.Price, eth.Price = step.btc, step.eth
+		if err := priceNotify.CheckNotifies(); err != nil {
+			t.Fatal(err)
+		}
+		if messages := ding.take(); !reflect.DeepEqual(messages, step.messages) {
+			t.Errorf("step %d: expected %v, got %v", i, step.messages, messages)
+		}
+	}
+	// the rules are reloaded in every check
+	dao.rules = append(dao.rules, &models.PriceNotify{Id: 3, TokenBasicName: "ETH", Percent: 50, Direction: basedef.NOTIFY_DIRECTION_DOWN})
+	eth.Price = 60000000000
+	priceNotify.CheckNotifies()
+	if messages := ding.take(); len(messages) != 0 {
+		t.Errorf("expected the new rule is applied, got %v", messages)
+	}
+	eth.Price = 56500000000
+	priceNotify.CheckNotifies()
+	if messages := ding.take(); !reflect.DeepEqual(messages, []string{"ETH price is down to 565"}) {
+		t.Errorf("expected ETH is notified by the new rule, got %v", messages)
+	}
+	// the state is carried over when the rule is changed
+	dao.rules[1].Hysteresis = 1
+	btc.Price = 4890000000000
+	priceNotify.CheckNotifies()
+	if messages := ding.take(); !reflect.DeepEqual(messages, []string{"BTC price is down to 48900, crossing 49000"}) {
+		t.Errorf("expected the crossing of the changed rule is notified, got %v", messages)
+	}
+}
+
+func TestPriceNotifyRestart(t *testing.T) {
+	ding := newDingServer(t)
+	defer ding.server.Close()
+	btc := &models.TokenBasic{Name: "BTC", Price: 4950000000000}
+	eth := &models.TokenBasic{Name: "ETH", Price: 60000000000}
+	dao := &mockPriceNotifyDao{
+		tokens: []*models.TokenBasic{btc, eth},
+		rules: []*models.PriceNotify{
+			{Id: 1, TokenBasicName: "BTC", Price: 5000000000000},
+			{Id: 2, Name: "spread", TokenBasicName: "BTC", Type: basedef.NOTIFY_RULE_SPREAD, Percent: 10, Markets: "binance,huobi"},
+		},
+	}
+	pricenotify.NewPriceNotify(1, ding.config(), dao)
+	if messages := ding.take(); len(messages) != 0 {
+		t.Errorf("unexpected messages: %v", messages)
+	}
+	if state := dao.states["ETH#band:0:10:0:0:"]; state == nil || state.Base != 60000000000 || state.Time != 0 {
+		t.Errorf("unexpected state of ETH: %+v", state)
+	}
+	btc.PriceMarkets = []*models.PriceMarket{
+		{MarketName: "binance", Price: 4950000000000, PriceInd: basedef.PRICE_IND_FRESH},
+		{MarketName: "huobi", Price: 5050000000000, PriceInd: basedef.PRICE_IND_FRESH},
+	}
+	priceNotify := pricenotify.NewPriceNotify(1, ding.config(), dao)
+	if messages := ding.take(); !reflect.DeepEqual(messages, []string{"BTC spread between binance and huobi is 2%, price is 49500"}) {
+		t.Errorf("unexpected messages: %v", messages)
+	}
+	if state := dao.states["BTC#spread"]; state == nil || state.NotifyPrice != 4950000000000 || state.Ind != -1 || state.Time == 0 {
+		t.Errorf("unexpected state of spread: %+v", state)
+	}
+	// the restart is silent, and the rules continue from the states before restart
+	eth.Price = 60500000000
+	priceNotify = pricenotify.NewPriceNotify(1, ding.config(), dao)
+	if messages := ding.take(); len(messages) != 0 {
+		t.Errorf("expected the restart is silent, got %v", messages)
+	}
+	eth.Price = 60700000000
+	if err := priceNotify.CheckNotifies(); err != nil {
+		t.Fatal(err)
+	}
+	if messages := ding.take(); !reflect.DeepEqual(messages, []string{"ETH price is up to 607"}) {
+		t.Errorf("expected ETH is notified from the band before restart, got %v", messages)
+	}
+	// the rules seeded again with new ids keep their states, and the states of the rules removed are deleted
+	dao.rules = []*models.PriceNotify{
+		{Id: 3, TokenBasicName: "BTC", Price: 5000000000000},
+		{Id: 4, TokenBasicName: "ETH", Percent: 10},
+	}
+	btc.Price = 5010000000000
+	priceNotify = pricenotify.NewPriceNotify(1, ding.config(), dao)
+	if messages := ding.take(); !reflect.DeepEqual(messages, []string{"BTC price is up to 50100, crossing 50000"}) {
+		t.Errorf("expected the crossing during restart is notified, got %v", messages)
+	}
+	if _, ok := dao.states["BTC#spread"]; ok || len(dao.states) != 2 {
+		t.Errorf("expected the state of removed rule is deleted, got %v", dao.states)
+	}
+	// a notification failed to send is not recorded
+	ding.server.Close()
+	btc.Price = 4900000000000
+	priceNotify.CheckNotifies()
+	if state := dao.states["BTC#cross:5000000000000:0:0:0:"]; state.Side != basedef.NOTIFY_DIRECTION_DOWN || state.Ind != 1 {
+		t.Errorf("unexpected state of failed notification: %+v", state)
+	}
+}
@@ -0,0 +1,200 @@
+package test
+
+import (
+	"price_notify/basedef"
+	"price_notify/models"
+	"price_notify/pricenotify"
+	"reflect"
+	"testing"
+)
+
+type priceTick struct {
+	time  int64
+	price int64
+	// the direction of the trigger expected at this tick, 0 if no trigger
+	ind int64
+}
+
+func runRule(t *testing.T, cfg *models.PriceNotify, ticks []priceTick) []*pricenotify.Trigger {
+	rule, err := pricenotify.NewRule(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	triggers := make([]*pricenotify.Trigger, 0)
+	for i, tick := range ticks {
+		token := &models.TokenBasic{Name: "BTC", Price: tick.price}
+		trigger := rule.Check(token, tick.time)
+		ind := int64(0)
+		if trigger != nil {
+			ind = trigger.Ind
+			triggers = append(triggers, trigger)
+		}
+		if ind != tick.ind {
+			t.Errorf("tick %d: expected %d, got %d", i, tick.ind, ind)
+		}
+	}
+	return triggers
+}
+
+func TestNewRule(t *testing.T) {
+	invalids := []*models.PriceNotify{
+		{Type: basedef.NOTIFY_RULE_CROSS},
+		{Type: basedef.NOTIFY_RULE_BAND},
+		{Type: basedef.NOTIFY_RULE_WINDOW, Percent: 50},
+		{Type: basedef.NOTIFY_RULE_SPREAD, Percent: 10, Markets: "binance"},
+		{Type: "unknown", Percent: 10},
+	}
+	for i, cfg := range invalids {
+		if _, err := pricenotify.NewRule(cfg); err == nil {
+			t.Errorf("rule %d: expected err", i)
+		}
+	}
+	if ruleType := pricenotify.RuleType(&models.PriceNotify{Price: 100}); ruleType != basedef.NOTIFY_RULE_CROSS {
+		t.Errorf("expected cross, got %s", ruleType)
+	}
+	if ruleType := pricenotify.RuleType(&models.PriceNotify{Percent: 10}); ruleType != basedef.NOTIFY_RULE_BAND {
+		t.Errorf("expected band, got %s", ruleType)
+	}
+}
+
+func TestCrossRule(t *testing.T) {
+	// the first price only sets the side, and the rule re-arms after the price crosses back
+	runRule(t, &models.PriceNotify{Price: 100}, []priceTick{
+		{0, 90, 0}, {1, 99, 0}, {2, 100, 1}, {3, 120, 0}, {4, 99, -1}, {5, 80, 0}, {6, 101, 1},
+	})
+	triggers := runRule(t, &models.PriceNotify{Price: 100, Direction: basedef.NOTIFY_DIRECTION_UP}, []priceTick{
+		{0, 110, 0}, {1, 90, 0}, {2, 110, 1}, {3, 90, 0},
+	})
+	if triggers[0].Type != basedef.NOTIFY_RULE_CROSS || triggers[0].Level != 100 || triggers[0].Time != 2 {
+		t.Errorf("unexpected trigger: %+v", triggers[0])
+	}
+}
+
+func TestBandRule(t *testing.T) {
+	// the band moves to the notified price, and moves silently in the other direction
+	runRule(t, &models.PriceNotify{Percent: 50, Direction: basedef.NOTIFY_DIRECTION_UP}, []priceTick{
+		{0, 1000, 0}, {1, 1050, 0}, {2, 1051, 1}, {3, 990, 0}, {4, 960, 0}, {5, 1000, 0}, {6, 1045, 1},
+	})
+}
+
+func TestWindowRule(t *testing.T) {
+	// -5% in 15 minutes
+	cfg := &models.PriceNotify{Type: basedef.NOTIFY_RULE_WINDOW, Percent: 50, Window: 900, Direction: basedef.NOTIFY_DIRECTION_DOWN}
+	triggers := runRule(t, cfg, []priceTick{
+		// a slow decline is not notified since the old prices leave the window
+		{0, 1000, 0}, {600, 980, 0}, {1200, 960, 0}, {1800, 940, 0}, {2400, 920, 0},
+		// a fast drop is notified once while it lasts
+		{2700, 870, -1}, {2800, 860, 0},
+		// the rule re-arms when the change goes back within the band
+		{3700, 870, 0}, {4000, 820, -1},
+		// a rise is not notified
+		{5000, 1000, 0},
+	})
+	if triggers[0].Percent != 74 || triggers[0].Window != 900 || triggers[0].Type != basedef.NOTIFY_RULE_WINDOW {
+		t.Errorf("unexpected trigger: %+v", triggers[0])
+	}
+	runRule(t, &models.PriceNotify{Type: basedef.NOTIFY_RULE_WINDOW, Percent: 50, Window: 60}, []priceTick{
+		{0, 1000, 0}, {30, 1100, 1}, {60, 1000, 0}, {120, 1100, 1},
+	})
+}
+
+func TestSpreadRule(t *testing.T) {
+	rule, err := pricenotify.NewRule(&models.PriceNotify{Type: basedef.NOTIFY_RULE_SPREAD, Percent: 10, Markets: "binance, huobi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		binance int64
+		huobi   int64
+		ind     uint64
+		expect  int64
+	}{
+		{1000, 1005, basedef.PRICE_IND_FRESH, 0},
+		{1000, 1020, basedef.PRICE_IND_FRESH, -1},
+		{1000, 1030, basedef.PRICE_IND_FRESH, 0},
+		// a stale market is ignored and keeps the state
+		{1000, 1030, basedef.PRICE_IND_STALE, 0},
+		{1000, 1010, basedef.PRICE_IND_FRESH, 0},
+		{1020, 1000, basedef.PRICE_IND_FRESH, 1},
+	}
+	for i, step := range steps {
+		token := &models.TokenBasic{
+			Name:  "BTC",
+			Price: step.binance,
+			PriceMarkets: []*models.PriceMarket{
+				{MarketName: "binance", Price: step.binance, PriceInd: basedef.PRICE_IND_FRESH},
+				{MarketName: "huobi", Price: step.huobi, PriceInd: step.ind},
+			},
+		}
+		ind := int64(0)
+		if trigger := rule.Check(token, int64(i)); trigger != nil {
+			ind = trigger.Ind
+			if trigger.Percent != 20 || !reflect.DeepEqual(trigger.Markets, []string{"binance", "huobi"}) {
+				t.Errorf("step %d: unexpected trigger: %+v", i, trigger)
+			}
+		}
+		if ind != step.expect {
+			t.Errorf("step %d: expected %d, got %d", i, step.expect, ind)
+		}
+	}
+}
+
+func TestPriceNotifyRuleMessages(t *testing.T) {
+	ding := newDingServer(t)
+	defer ding.server.Close()
+	btc := &models.TokenBasic{
+		Name:  "BTC",
+		Price: 5000000000000,
+		PriceMarkets: []*models.PriceMarket{
+			{MarketName: "binance", Price: 5000000000000, PriceInd: basedef.PRICE_IND_FRESH},
+			{MarketName: "huobi", Price: 5000000000000, PriceInd: basedef.PRICE_IND_FRESH},
+		},
+	}
+	dao := &mockPriceNotifyDao{
+		tokens: []*models.TokenBasic{btc},
+		rules: []*models.PriceNotify{
+			{Id: 1, TokenBasicName: "BTC", Type: basedef.NOTIFY_RULE_WINDOW, Percent: 50, Window: 900},
+			{Id: 2, TokenBasicName: "BTC", Type: basedef.NOTIFY_RULE_SPREAD, Percent: 10, Markets: "binance,huobi"},
+			{Id: 3, TokenBasicName: "BTC", Type: "unknown"},
+		},
+	}
+	priceNotify := pricenotify.NewPriceNotify(1, ding.config(), dao)
+	if messages := ding.take(); len(messages) != 0 {
+		t.Errorf("unexpected messages: %v", messages)
+	}
+	btc.Price = 4700000000000
+	btc.PriceMarkets[0].Price = 4700000000000
+	if err := priceNotify.CheckNotifies(); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"BTC price is down 6% in 900s to 47000",
+		"BTC spread between binance and huobi is 6.3%, price is 47000",
+	}
+	if messages := ding.take(); !reflect.DeepEqual(messages, expected) {
+		t.Errorf("expected %v, got %v", expected, messages)
+	}
+}
+
+func TestRuleHysteresis(t *testing.T) {
+	// the upward crossing at 1000 is armed again below 980
+	runRule(t, &models.PriceNotify{Price: 1000, Hysteresis: 20, Direction: basedef.NOTIFY_DIRECTION_UP}, []priceTick{
+		{0, 990, 0}, {1, 1000, 1}, {2, 990, 0}, {3, 1001, 0}, {4, 979, 0}, {5, 1000, 1},
+	})
+	// the crossings in both directions are notified beyond the band around 1000
+	runRule(t, &models.PriceNotify{Price: 1000, Hysteresis: 20}, []priceTick{
+		{0, 990, 0}, {1, 1010, 0}, {2, 1020, 1}, {3, 990, 0}, {4, 979, -1}, {5, 1005, 0},
+	})
+	runRule(t, &models.PriceNotify{Type: basedef.NOTIFY_RULE_WINDOW, Percent: 50, Hysteresis: 20, Window: 60}, []priceTick{
+		{0, 1000, 0}, {10, 1060, 1}, {20, 1040, 0}, {30, 1060, 0}, {40, 1020, 0}, {50, 1060, 1},
+	})
+	if _, err := pricenotify.NewRule(&models.PriceNotify{Type: basedef.NOTIFY_RULE_WINDOW, Percent: 50, Hysteresis: 50, Window: 60}); err == nil {
+		t.Errorf("expected err of hysteresis not less than percent")
+	}
+	if _, err := pricenotify.NewRule(&models.PriceNotify{Percent: 50, Hysteresis: 20}); err == nil {
+		t.Errorf("expected err of hysteresis of band rule")
+	}
+	if _, err := pricenotify.NewRule(&models.PriceNotify{Price: 1000, Cooldown: -1}); err == nil {
+		t.Errorf("expected err of negative cooldown")
+	}
+}
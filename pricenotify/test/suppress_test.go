@@ -0,0 +1,134 @@
+package test
+
+import (
+	"price_notify/models"
+	"price_notify/pricenotify"
+	"testing"
+)
+
+type suppressStep struct {
+	time  int64
+	price int64
+	ind   int64
+	flaps int64
+}
+
+func runSuppressor(t *testing.T, suppressor *pricenotify.Suppressor, steps []suppressStep) {
+	rule, err := pricenotify.NewRule(&models.PriceNotify{Price: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, step := range steps {
+		trigger := suppressor.Filter(rule.Check(&models.TokenBasic{Name: "BTC", Price: step.price}, step.time), step.time)
+		ind, flaps := int64(0), int64(0)
+		if trigger != nil {
+			ind, flaps = trigger.Ind, trigger.Flaps
+			suppressor.Sent(step.time)
+		}
+		if ind != step.ind || flaps != step.flaps {
+			t.Errorf("step %d: expected %d with %d flaps, got %d with %d flaps", i, step.ind, step.flaps, ind, flaps)
+		}
+	}
+}
+
+func TestSuppressorCooldown(t *testing.T) {
+	runSuppressor(t, pricenotify.NewSuppressor(600, 0, 0, 0), []suppressStep{
+		{10000, 990, 0, 0},
+		{10060, 1010, 1, 0},
+		// a single notification in the cooldown is sent when the cooldown ends
+		{10120, 990, 0, 0},
+		{10660, 980, -1, 0},
+		// the rule triggered 3 times in the flap window is flapping, and the toggles are collapsed
+		{10720, 1010, 0, 0},
+		{10780, 990, 0, 0},
+		{10840, 1010, 0, 0},
+		{11260, 1020, 0, 0},
+		{13600, 1030, 1, 3},
+		{13660, 990, 0, 0},
+		{14200, 990, -1, 0},
+	})
+}
+
+func TestSuppressorWithoutCooldown(t *testing.T) {
+	runSuppressor(t, pricenotify.NewSuppressor(0, 0, 0, 0), []suppressStep{
+		{10000, 990, 0, 0},
+		{10060, 1010, 1, 0},
+		{10120, 990, -1, 0},
+		{10180, 1010, 0, 0},
+		{10240, 990, 0, 0},
+		{13600, 990, -1, 2},
+		{13660, 1010, 1, 0},
+	})
+}
+
+func TestSuppressorFlapConfig(t *testing.T) {
+	// a rule triggered 2 times in 600 seconds is flapping, and a single notification held back is not collapsed
+	runSuppressor(t, pricenotify.NewSuppressor(0, 3, 2, 600), []suppressStep{
+		{10000, 990, 0, 0},
+		{10060, 1010, 1, 0},
+		{10120, 990, 0, 0},
+		{10600, 990, -1, 0},
+		{10660, 1010, 1, 0},
+	})
+}
+
+func TestSuppressorNotSent(t *testing.T) {
+	suppressor := pricenotify.NewSuppressor(600, 0, 0, 0)
+	trigger := &pricenotify.Trigger{TokenName: "BTC", Ind: 1}
+	if notify := suppressor.Filter(trigger, 10000); notify != trigger {
+		t.Fatalf("expected the trigger, got %+v", notify)
+	}
+	// the notification not sent is returned again
+	if notify := suppressor.Filter(nil, 10060); notify != trigger {
+		t.Fatalf("expected the trigger again, got %+v", notify)
+	}
+	suppressor.Sent(10060)
+	if notify := suppressor.Filter(nil, 10120); notify != nil {
+		t.Errorf("unexpected notification: %+v", notify)
+	}
+	// the suppressor is restored from the state
+	suppressor.Filter(&pricenotify.Trigger{TokenName: "BTC", Ind: -1}, 10180)
+	state := &models.PriceNotifyState{}
+	suppressor.State(state)
+	restored := pricenotify.NewSuppressor(600, 0, 0, 0)
+	restored.Restore(state)
+	if notify := restored.Filter(nil, 10600); notify != nil {
+		t.Errorf("expected the cooldown is restored, got %+v", notify)
+	}
+	if notify := restored.Filter(nil, 10660); notify == nil || notify.Ind != -1 || notify.Flaps != 0 {
+		t.Errorf("expected the notification held back is restored, got %+v", notify)
+	}
+}
+
+func TestPriceNotifyFlapping(t *testing.T) {
+	ding := newDingServer(t)
+	defer ding.server.Close()
+	btc := &models.TokenBasic{Name: "BTC", Price: 4990000000000}
+	dao := &mockPriceNotifyDao{
+		tokens: []*models.TokenBasic{btc},
+		rules:  []*models.PriceNotify{{Id: 1, Name: "level", TokenBasicName: "BTC", Price: 5000000000000, Cooldown: 3600}},
+	}
+	priceNotify := pricenotify.NewPriceNotify(1, ding.config(), dao)
+	for _, price := range []int64{5010000000000, 4990000000000, 5010000000000, 4990000000000} {
+		btc.Price = price
+		if err := priceNotify.CheckNotifies(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// only the first crossing is sent, and the rest are held back
+	if messages := ding.take(); len(messages) != 1 || messages[0] != "BTC price is up to 50100, crossing 50000" {
+		t.Errorf("unexpected messages: %v", messages)
+	}
+	state := dao.states["BTC#level"]
+	if state.PendingCount != 3 || state.Ind != 1 || state.Time == 0 || state.Pending == "" {
+		t.Errorf("unexpected state: %+v", state)
+	}
+	// the notifications held back are kept across restart
+	pricenotify.NewPriceNotify(1, ding.config(), dao)
+	if messages := ding.take(); len(messages) != 0 {
+		t.Errorf("expected the restart is silent, got %v", messages)
+	}
+	if restored := dao.states["BTC#level"]; *restored != *state {
+		t.Errorf("expected the state is kept, got %+v", restored)
+	}
+}
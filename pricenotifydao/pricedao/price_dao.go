@@ -61,7 +61,10 @@ func (dao *PriceDao) AddNotifies(notifies []*models.PriceNotify) error {
 
 func (dao *PriceDao) GetNotifies() ([]*models.PriceNotify, error) {
 	priceNotifies := make([]*models.PriceNotify, 0)
-	dao.db.Preload("TokenBasic").Find(&priceNotifies)
+	res := dao.db.Preload("TokenBasic").Find(&priceNotifies)
+	if res.Error != nil {
+		return nil, res.Error
+	}
 	return priceNotifies, nil
 }
 
@@ -77,6 +80,35 @@ func (dao *PriceDao) GetTokens() ([]*models.TokenBasic, error) {
 	return tokens, nil
 }
 
+func (dao *PriceDao) GetNotifyStates() ([]*models.PriceNotifyState, error) {
+	states := make([]*models.PriceNotifyState, 0)
+	res := dao.db.Find(&states)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	return states, nil
+}
+
+func (dao *PriceDao) SaveNotifyStates(states []*models.PriceNotifyState) error {
+	if states != nil && len(states) > 0 {
+		res := dao.db.Save(states)
+		if res.Error != nil {
+			return res.Error
+		}
+	}
+	return nil
+}
+
+func (dao *PriceDao) DeleteNotifyStates(ruleKeys []string) error {
+	if ruleKeys != nil && len(ruleKeys) > 0 {
+		res := dao.db.Where("rule_key in ?", ruleKeys).Delete(&models.PriceNotifyState{})
+		if res.Error != nil {
+			return res.Error
+		}
+	}
+	return nil
+}
+
 func (dao *PriceDao) Name() string {
 	return basedef.SERVER_PRICE
 }
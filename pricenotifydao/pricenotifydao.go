@@ -29,6 +29,9 @@ type PriceNotifyDao interface {
 	AddNotifies([]*models.PriceNotify) error
 	GetNotifies() ([]*models.PriceNotify, error)
 	GetTokens() ([]*models.TokenBasic, error)
+	GetNotifyStates() ([]*models.PriceNotifyState, error)
+	SaveNotifyStates([]*models.PriceNotifyState) error
+	DeleteNotifyStates([]string) error
 	Name() string
 }
 
@@ -42,6 +42,18 @@ func (dao *StakeDao) GetTokens() ([]*models.TokenBasic, error) {
 	return nil, nil
 }
 
+func (dao *StakeDao) GetNotifyStates() ([]*models.PriceNotifyState, error) {
+	return nil, nil
+}
+
+func (dao *StakeDao) SaveNotifyStates([]*models.PriceNotifyState) error {
+	return nil
+}
+
+func (dao *StakeDao) DeleteNotifyStates([]string) error {
+	return nil
+}
+
 func (dao *StakeDao) Name() string {
 	return basedef.SERVER_STAKE
 }
@@ -37,7 +37,7 @@ func startUpdate(cfg *UpdateConfig) {
 	if err != nil {
 		panic(err)
 	}
-	err = db.Debug().AutoMigrate(&models.TokenBasic{}, &models.PriceMarket{}, &models.PriceNotify{})
+	err = db.Debug().AutoMigrate(&models.TokenBasic{}, &models.PriceMarket{}, &models.PriceNotify{}, &models.PriceHistory{}, &models.PriceCandle{}, &models.PriceNotifyState{})
 	if err != nil {
 		panic(err)
 	}